@@ -1,18 +1,37 @@
 package main
 
 import (
+	"CodeBorrowing/internal/admin"
+	"CodeBorrowing/internal/checker"
 	"CodeBorrowing/internal/config"
+	"CodeBorrowing/internal/fingerprint"
+	"CodeBorrowing/internal/ignore"
+	"CodeBorrowing/internal/reference"
 	"CodeBorrowing/internal/router"
+	"CodeBorrowing/internal/submission"
 	"CodeBorrowing/internal/task"
 	"CodeBorrowing/pkg/logger"
 	"CodeBorrowing/pkg/shutdown"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"syscall"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 func main() {
+	// Процесс запущен как песочница для проверяющей программы.
+	if checker.IsSandboxProcess() {
+		checker.RunSandboxProcess()
+		return
+	}
+
 	// Чтение переменных окружающей среды.
 	cfg, err := config.GetConfig()
 	if err != nil {
@@ -20,8 +39,24 @@ func main() {
 		return
 	}
 
+	logLevels, err := logger.ParseLevelOptions(cfg.LogConsoleLevel, cfg.LogFileLevel, cfg.LogPackageLevels)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
 	// Инициализация логгера.
-	appLogger := logger.GetLogger(cfg.Logs)
+	appLogger := logger.GetLogger(logger.Options{
+		Path: cfg.Logs,
+		Rotation: logger.RotationOptions{
+			MaxSize:  cfg.LogMaxSize * 1024 * 1024,
+			Interval: cfg.LogRotateInterval,
+			Compress: cfg.LogCompress,
+			MaxFiles: cfg.LogMaxFiles,
+			MaxAge:   cfg.LogMaxAge,
+		},
+		Levels: logLevels,
+	})
 	appLogger.Debug("Logger initialized")
 
 	// Инициализация хранилища работ студентов.
@@ -35,12 +70,164 @@ func main() {
 	router.InitializeHost(cfg.MainServerHost, cfg.MainServerKey)
 
 	// Сервис и обработчик для обработки работ студентов.
-	taskService, err := task.NewService(taskStorage, appLogger, cfg.Storage, cfg.StorageSize)
+	fingerprintOptions := fingerprint.Options{
+		K:      int(cfg.FingerprintK),
+		Window: int(cfg.FingerprintWindow),
+	}
+
+	// Локальный список общеизвестного кода, который не считается заимствованием.
+	ignoreCorpus, err := ignore.NewCorpus(cfg.IgnoreCorpus, fingerprintOptions, cfg.SubmissionLanguage)
 	if err != nil {
 		appLogger.Error(err)
 		return
 	}
-	taskHandler := task.NewHandler(appLogger, taskService)
+	appLogger.Debugf("Ignore corpus initialized, version %d", ignoreCorpus.Version())
+
+	// Локальные корпуса известных открытых исходников, индексируются один раз при запуске.
+	externalNames := make([]string, 0, len(cfg.ExternalCorpora))
+	for name := range cfg.ExternalCorpora {
+		externalNames = append(externalNames, name)
+	}
+	sort.Strings(externalNames)
+
+	externalCorpora := make([]*reference.Corpus, 0, len(externalNames))
+	for _, name := range externalNames {
+		corpus, err := reference.Load(name, cfg.ExternalCorpora[name], fingerprintOptions, cfg.SubmissionLanguage)
+		if err != nil {
+			appLogger.Error(err)
+			return
+		}
+		externalCorpora = append(externalCorpora, corpus)
+		appLogger.Debugf("External corpus \"%s\" indexed, %d entries", name, corpus.Len())
+	}
+
+	evictionPolicy := task.EvictionPolicy{
+		MaxSize:        cfg.StorageSize,
+		HighWatermark:  cfg.StorageHighWatermark,
+		LowWatermark:   cfg.StorageLowWatermark,
+		TTL:            cfg.StorageTTL,
+		BatchSize:      cfg.StorageEvictionBatch,
+		ActiveEventTTL: cfg.StorageActiveEventTTL,
+	}
+	// Правила отбора файлов работ для сравнения.
+	submissionRules := submission.DefaultRules(cfg.SubmissionLanguage)
+	if len(cfg.SubmissionInclude) != 0 {
+		submissionRules.Include = cfg.SubmissionInclude
+	}
+	submissionRules.Exclude = append(submissionRules.Exclude, cfg.SubmissionExclude...)
+	submissionRules.MaxFileSize = cfg.SubmissionMaxFileSize * 1024
+
+	// Проверяющая программа выбирается по имени из настроек.
+	checkerConfig := checker.BackendConfig{
+		Path:    cfg.CheckerPath,
+		Results: filepath.Join(cfg.Storage, "results"),
+		Options: checker.Options{
+			Language:    cfg.SubmissionLanguage,
+			Timeout:     cfg.CheckerTimeout,
+			MaxHeap:     cfg.CheckerMaxHeap,
+			MaxStack:    cfg.CheckerMaxStack,
+			Processors:  cfg.CheckerProcessors,
+			JavaOptions: cfg.CheckerJavaOptions,
+			KeepFailed:  cfg.CheckerKeepFailed,
+			Sandbox: checker.SandboxOptions{
+				Enabled:   cfg.CheckerSandbox,
+				Root:      cfg.Storage,
+				User:      cfg.CheckerSandboxUser,
+				MaxCPU:    cfg.CheckerMaxCPU,
+				MaxMemory: cfg.CheckerMaxMemory,
+				MaxFiles:  cfg.CheckerMaxFiles,
+				TempSize:  cfg.CheckerTempSize,
+			},
+		},
+		Daemon: checker.DaemonOptions{
+			Args:    cfg.CheckerDaemonArgs,
+			MaxJobs: cfg.CheckerDaemonMaxJobs,
+		},
+		External: checker.ExternalOptions{
+			Command: cfg.CheckerCommand,
+			Args:    cfg.CheckerArgs,
+			Options: cfg.CheckerOptions,
+		},
+		Structure: checker.StructureOptions{
+			MinTokens: cfg.CheckerAstMinTokens,
+			Weight:    cfg.CheckerAstWeight,
+		},
+	}
+
+	// Сохранённые результаты пар работ используются, пока не изменятся проверяющая программа и её настройки.
+	resultKey := ""
+	if cfg.CheckerResultCache {
+		if resultKey, err = checker.ResultKey(cfg.CheckerBackend, checkerConfig); err != nil {
+			appLogger.Error(err)
+			return
+		}
+	}
+
+	taskService, err := task.NewService(taskStorage, appLogger, task.ServiceOptions{
+		Path:              cfg.Storage,
+		Eviction:          evictionPolicy,
+		ReconcileInterval: cfg.StorageReconcileInterval,
+		Fingerprint:       fingerprintOptions,
+		Ignore:            ignoreCorpus,
+		Rules:             submissionRules,
+		Language:          cfg.SubmissionLanguage,
+		CrossLanguage: task.CrossLanguageOptions{
+			K:             int(cfg.CrossLanguageK),
+			MinSimilarity: cfg.CrossLanguageMinSimilarity,
+		},
+		KnownCodeOverlap:  cfg.KnownCodeOverlap,
+		ResultKey:         resultKey,
+		ReferenceArchives: cfg.ReferenceArchives,
+		External: task.ExternalOptions{
+			Corpora:       externalCorpora,
+			MinSimilarity: cfg.ExternalMinSimilarity,
+			MinLines:      cfg.ExternalMinLines,
+		},
+	})
+	if err != nil {
+		appLogger.Error(err)
+		return
+	}
+
+	taskChecker, err := checker.New(cfg.CheckerBackend, appLogger, checkerConfig)
+	if err != nil {
+		appLogger.Error(err)
+		return
+	}
+	// Структурное сравнение дополняет результаты проверяющей программы с заданным весом.
+	if cfg.CheckerAstWeight > 0 && cfg.CheckerBackend != checker.BackendAST {
+		structuralChecker, err := checker.New(checker.BackendAST, appLogger, checkerConfig)
+		if err != nil {
+			appLogger.Error(err)
+			return
+		}
+		if taskChecker, err = checker.NewCombinedChecker(appLogger, taskChecker, structuralChecker, cfg.CheckerAstWeight); err != nil {
+			appLogger.Error(err)
+			return
+		}
+	}
+
+	taskHandler := task.NewHandler(appLogger, taskService, taskChecker, task.HandlerOptions{
+		SkipExact:         cfg.CheckerSkipExact,
+		Candidates:        cfg.CheckerCandidates,
+		PrefilterMinWorks: cfg.CheckerPrefilterMinWork,
+		CrossLanguage:     cfg.CrossLanguage,
+	})
+
+	// Административный сервер для управления приложением во время работы.
+	var adminServer *http.Server
+	if cfg.AdminHost != "" {
+		adminRouter := mux.NewRouter()
+		admin.NewHandler(appLogger, cfg.AdminKey, ignoreCorpus, taskService).Register(adminRouter)
+		adminServer = &http.Server{Addr: cfg.AdminHost, Handler: adminRouter}
+
+		go func() {
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				appLogger.Error(err)
+			}
+		}()
+		appLogger.Debug("Admin server started")
+	}
 
 	quit := make(chan interface{})               // Сюда придёт сигнал, что надо завершить приложение.
 	scheduler := time.NewTicker(5 * time.Second) // Будильник для проверки новой задачи.
@@ -64,6 +251,12 @@ func main() {
 
 	appLogger.Info("Finishing the program")
 
+	if adminServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = adminServer.Shutdown(ctx)
+		cancel()
+	}
+
 	_ = taskStorage.Close()
 	_ = appLogger.Close()
 	// TODO: Save data
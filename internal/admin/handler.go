@@ -0,0 +1,193 @@
+package admin
+
+import (
+	"CodeBorrowing/internal/apperror"
+	"CodeBorrowing/internal/ignore"
+	"CodeBorrowing/pkg/logger"
+	"CodeBorrowing/pkg/web/mime"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	urlLogLevels    = "/admin/log/levels"
+	urlIgnoreCorpus = "/admin/ignore"
+
+	headerAdminKey = "X-Admin-Key"
+)
+
+type Handler interface {
+	Register(router *mux.Router)
+}
+
+// SnippetSource gives lines of files of cached works.
+type SnippetSource interface {
+	GetWorkSnippet(workId uint64, path string, start uint64, size uint64) ([]byte, error)
+}
+
+type handler struct {
+	logger   *logger.Logger
+	key      string
+	corpus   ignore.Corpus
+	snippets SnippetSource
+}
+
+func NewHandler(appLogger *logger.Logger, key string, corpus ignore.Corpus, snippets SnippetSource) Handler {
+	return &handler{
+		logger:   appLogger,
+		key:      key,
+		corpus:   corpus,
+		snippets: snippets,
+	}
+}
+
+func (h *handler) Register(router *mux.Router) {
+	router.HandleFunc(urlLogLevels, apperror.Middleware(h.authorized(h.GetLogLevels))).Methods(http.MethodGet)
+	router.HandleFunc(urlLogLevels, apperror.Middleware(h.authorized(h.UpdateLogLevels))).Methods(http.MethodPut)
+	router.HandleFunc(urlIgnoreCorpus, apperror.Middleware(h.authorized(h.GetIgnoreCorpus))).Methods(http.MethodGet)
+	router.HandleFunc(urlIgnoreCorpus, apperror.Middleware(h.authorized(h.AddIgnoreSnippet))).Methods(http.MethodPost)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set(mime.ContentType, mime.ApplicationJSON)
+	w.WriteHeader(statusCode)
+	_, err = w.Write(body)
+	return err
+}
+
+// authorized lets through requests with the admin key. Without the key nothing is let through.
+// The key is compared in constant time, so it can not be guessed by timing.
+func (h *handler) authorized(next func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		key := r.Header.Get(headerAdminKey)
+		if h.key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(h.key)) != 1 {
+			return apperror.ErrUnauthorized
+		}
+		return next(w, r)
+	}
+}
+
+func (h *handler) writeLogLevels(w http.ResponseWriter) error {
+	levels := h.logger.GetLevels()
+	dto := LogLevelsDTO{
+		Console:  levels.Console.String(),
+		File:     levels.File.String(),
+		Packages: make(map[string]string, len(levels.Packages)),
+	}
+	for pkg, level := range levels.Packages {
+		dto.Packages[pkg] = level.String()
+	}
+
+	return writeJSON(w, http.StatusOK, dto)
+}
+
+func (h *handler) GetLogLevels(w http.ResponseWriter, _ *http.Request) error {
+	return h.writeLogLevels(w)
+}
+
+// UpdateLogLevels changes only the levels present in the request.
+// An empty package level removes the override of the package.
+func (h *handler) UpdateLogLevels(w http.ResponseWriter, r *http.Request) error {
+	var dto LogLevelsDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError(err.Error())
+	}
+
+	// Validate everything before applying, so a bad request changes nothing.
+	outputs := map[string]string{logger.OutputConsole: dto.Console, logger.OutputFile: dto.File}
+	outputLevels := make(map[string]logrus.Level, len(outputs))
+	for output, levelStr := range outputs {
+		if levelStr == "" {
+			continue
+		}
+		level, err := logrus.ParseLevel(levelStr)
+		if err != nil {
+			return apperror.BadRequestError(err.Error())
+		}
+		outputLevels[output] = level
+	}
+
+	packageLevels := make(map[string]logrus.Level, len(dto.Packages))
+	for pkg, levelStr := range dto.Packages {
+		if levelStr == "" {
+			continue
+		}
+		level, err := logrus.ParseLevel(levelStr)
+		if err != nil {
+			return apperror.BadRequestError(err.Error())
+		}
+		packageLevels[pkg] = level
+	}
+
+	for output, level := range outputLevels {
+		if err := h.logger.SetOutputLevel(output, level); err != nil {
+			return err
+		}
+	}
+	for pkg, levelStr := range dto.Packages {
+		if levelStr == "" {
+			h.logger.ResetPackageLevel(pkg)
+		} else {
+			h.logger.SetPackageLevel(pkg, packageLevels[pkg])
+		}
+	}
+
+	h.logger.Infof("Log levels changed: %+v", dto)
+	return h.writeLogLevels(w)
+}
+
+func (h *handler) GetIgnoreCorpus(w http.ResponseWriter, _ *http.Request) error {
+	return writeJSON(w, http.StatusOK, IgnoreCorpusDTO{
+		Version:  h.corpus.Version(),
+		Snippets: h.corpus.List(),
+	})
+}
+
+// AddIgnoreSnippet adds the snippet to the ignore corpus.
+func (h *handler) AddIgnoreSnippet(w http.ResponseWriter, r *http.Request) error {
+	var dto AddSnippetDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError(err.Error())
+	}
+
+	if dto.Name == "" {
+		return apperror.BadRequestError("snippet name is required")
+	}
+
+	content := []byte(dto.Content)
+	if dto.WorkID != 0 {
+		if dto.File == "" {
+			return apperror.BadRequestError("file of the work is required")
+		}
+
+		var err error
+		if content, err = h.snippets.GetWorkSnippet(dto.WorkID, dto.File, dto.Start, dto.Size); err != nil {
+			return apperror.BadRequestError(err.Error())
+		}
+		if dto.Source == "" {
+			dto.Source = fmt.Sprintf("work %d: %s:%d+%d", dto.WorkID, dto.File, dto.Start, dto.Size)
+		}
+	}
+
+	snippet, err := h.corpus.Add(dto.Name, dto.Source, content)
+	if err != nil {
+		if errors.Is(err, ignore.ErrEmptySnippet) {
+			return apperror.BadRequestError(err.Error())
+		}
+		return err
+	}
+
+	h.logger.Infof("Snippet %d \"%s\" added to the ignore corpus, version %d", snippet.ID, snippet.Name, h.corpus.Version())
+	return writeJSON(w, http.StatusCreated, snippet)
+}
@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"CodeBorrowing/internal/apperror"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		header  string
+		allowed bool
+	}{
+		{"right key", "secret", "secret", true},
+		{"wrong key", "secret", "secreT", false},
+		{"missing key", "secret", "", false},
+		{"no key configured", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{key: tt.key}
+			called := false
+			next := h.authorized(func(http.ResponseWriter, *http.Request) error {
+				called = true
+				return nil
+			})
+
+			r := httptest.NewRequest(http.MethodGet, urlLogLevels, nil)
+			if tt.header != "" {
+				r.Header.Set(headerAdminKey, tt.header)
+			}
+			err := next(httptest.NewRecorder(), r)
+
+			if called != tt.allowed {
+				t.Errorf("called = %v, want %v", called, tt.allowed)
+			}
+			if !tt.allowed && !errors.Is(err, apperror.ErrUnauthorized) {
+				t.Errorf("error = %v, want %v", err, apperror.ErrUnauthorized)
+			}
+		})
+	}
+}
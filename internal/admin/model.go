@@ -0,0 +1,27 @@
+package admin
+
+import "CodeBorrowing/internal/ignore"
+
+type LogLevelsDTO struct {
+	Console  string            `json:"console,omitempty"`
+	File     string            `json:"file,omitempty"`
+	Packages map[string]string `json:"packages,omitempty"`
+}
+
+type IgnoreCorpusDTO struct {
+	Version  uint64           `json:"version"`
+	Snippets []ignore.Snippet `json:"snippets"`
+}
+
+// AddSnippetDTO adds either the content or lines of a file of a cached work
+// (e.g. a match of a false positive report).
+type AddSnippetDTO struct {
+	Name    string `json:"name"`
+	Source  string `json:"source,omitempty"`
+	Content string `json:"content,omitempty"`
+
+	WorkID uint64 `json:"work_id,omitempty"`
+	File   string `json:"file,omitempty"`
+	Start  uint64 `json:"start,omitempty"`
+	Size   uint64 `json:"size,omitempty"`
+}
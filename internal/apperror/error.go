@@ -43,3 +43,4 @@ func SystemError(developerMessage string) *AppError {
 }
 
 var ErrNotFound = NewAppError("Not Found", string(rune(http.StatusNotFound)), "")
+var ErrUnauthorized = NewAppError("Unauthorized", string(rune(http.StatusUnauthorized)), "")
@@ -25,6 +25,8 @@ func Middleware(handler appHandler) http.HandlerFunc {
 		statusCode := http.StatusBadRequest
 		if errors.Is(appErr, ErrNotFound) {
 			statusCode = http.StatusNotFound
+		} else if errors.Is(appErr, ErrUnauthorized) {
+			statusCode = http.StatusUnauthorized
 		}
 
 		w.WriteHeader(statusCode)
@@ -0,0 +1,103 @@
+package charset
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Legacy encodings in the order of preference for file names.
+// Windows archivers write names in the OEM code page, editors save files in the ANSI one.
+var (
+	nameEncodings = []*charmap.Charmap{charmap.CodePage866, charmap.Windows1251}
+	textEncodings = []*charmap.Charmap{charmap.Windows1251, charmap.CodePage866}
+)
+
+// Name returns the file name in UTF-8.
+// Names, which are not valid UTF-8, are decoded from the most likely legacy encoding.
+func Name(name string) string {
+	if utf8.ValidString(name) {
+		return name
+	}
+	return string(decodeLegacy([]byte(name), nameEncodings))
+}
+
+// Text converts the content of a source file to UTF-8 without a BOM and with "\n" line endings.
+// UTF-16 is recognized by the BOM, other content, which is not valid UTF-8, is decoded
+// from the most likely legacy encoding.
+func Text(content []byte) []byte {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		content = content[len(bomUTF8):]
+	case bytes.HasPrefix(content, bomUTF16LE):
+		content = decode(content, xunicode.UTF16(xunicode.LittleEndian, xunicode.ExpectBOM))
+	case bytes.HasPrefix(content, bomUTF16BE):
+		content = decode(content, xunicode.UTF16(xunicode.BigEndian, xunicode.ExpectBOM))
+	case !utf8.Valid(content):
+		content = decodeLegacy(content, textEncodings)
+	}
+
+	return normalizeLineEndings(content)
+}
+
+func decode(content []byte, enc encoding.Encoding) []byte {
+	res, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return content
+	}
+	return res
+}
+
+// decodeLegacy decodes the content from the encoding, which gives the most natural text.
+// The first encoding wins, if the scores are equal.
+func decodeLegacy(content []byte, encodings []*charmap.Charmap) []byte {
+	var best []byte
+	bestScore := 0
+
+	for i, enc := range encodings {
+		res := decode(content, enc)
+		if score := textScore(res); i == 0 || score > bestScore {
+			best, bestScore = res, score
+		}
+	}
+
+	return best
+}
+
+// textScore estimates how natural the text is: lowercase letters are the most frequent
+// in words, pseudographics and rare symbols almost never appear in names and sources.
+func textScore(text []byte) int {
+	score := 0
+	for _, r := range string(text) {
+		switch {
+		case r < utf8.RuneSelf:
+		case unicode.IsLower(r):
+			score += 2
+		case unicode.IsUpper(r):
+			score++
+		default:
+			score -= 3
+		}
+	}
+	return score
+}
+
+// normalizeLineEndings replaces "\r\n" and single "\r" with "\n".
+func normalizeLineEndings(content []byte) []byte {
+	if bytes.IndexByte(content, '\r') < 0 {
+		return content
+	}
+
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+}
@@ -2,50 +2,160 @@ package checker
 
 import (
 	"CodeBorrowing/pkg/logger"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// killWaitDelay is the time given to the killed checker to close its output.
+const killWaitDelay = 5 * time.Second
+
 var ErrNoFiles = errors.New("no files for comparison")
 
+// ErrPartialResult is returned with the result, which lacks a part of comparisons.
+// The result may be reported, but must not be stored for later runs.
+var ErrPartialResult = errors.New("result is partial")
+
+// Checker compares the new work with old ones and returns the path of the file with results:
+// a JSON array of comparisons of works in the shape of task.ResultDTO.
+// Each run has its own result, which must be released, when it is not needed anymore.
+// A partial result comes with ErrPartialResult and must be released as well.
 type Checker interface {
 	Run(newWork string, oldWorks []string) (string, error)
+	Release(resultPath string, failed bool)
+}
+
+// Options limit resources of the checker process.
+type Options struct {
+	Language    string        // Language of submissions.
+	Timeout     time.Duration // Wall-clock limit of one run (0 - unlimited).
+	MaxHeap     uint64        // Max heap of the JVM in megabytes (0 - default of the JVM).
+	MaxStack    uint64        // Stack size of JVM threads in kilobytes (0 - default of the JVM).
+	Processors  uint64        // Count of processors available to the JVM (0 - all).
+	JavaOptions []string      // Additional options of the JVM.
+	KeepFailed  bool          // Keep results of failed runs for debugging.
+
+	Sandbox SandboxOptions
 }
 
 type checkerT struct {
 	logger      *logger.Logger
 	checkerPath string
-	resultPath  string
+	results     *resultDirs
+	options     Options
+	sandbox     *sandbox // nil - the checker runs without restrictions.
 }
 
-func NewChecker(appLogger *logger.Logger, checker string, result string) Checker {
+// NewChecker creates the checker, which runs the jar for each task.
+// Results of runs are kept in directories inside the results root.
+func NewChecker(appLogger *logger.Logger, checker string, results string, options Options) Checker {
+	return newCheckerT(appLogger, checker, results, options)
+}
+
+func newCheckerT(appLogger *logger.Logger, checker string, results string, options Options) *checkerT {
 	return &checkerT{
 		logger:      appLogger,
 		checkerPath: checker,
-		resultPath:  result,
+		results:     newResultDirs(appLogger, results, options.KeepFailed),
+		options:     options,
+		sandbox:     newSandbox(appLogger, options.Sandbox),
+	}
+}
+
+// javaArgs returns arguments of the JVM, which apply the resource limits.
+func (c *checkerT) javaArgs() []string {
+	args := []string{"-XX:+ExitOnOutOfMemoryError"}
+	if c.options.MaxHeap != 0 {
+		args = append(args, fmt.Sprintf("-Xmx%dm", c.options.MaxHeap))
+	}
+	if c.options.MaxStack != 0 {
+		args = append(args, fmt.Sprintf("-Xss%dk", c.options.MaxStack))
+	}
+	if c.options.Processors != 0 {
+		args = append(args, fmt.Sprintf("-XX:ActiveProcessorCount=%d", c.options.Processors))
 	}
+	return append(args, c.options.JavaOptions...)
 }
 
 func (c *checkerT) Run(newWork string, oldWorks []string) (string, error) {
 	if newWork == "" || len(oldWorks) == 0 {
 		return "", ErrNoFiles
 	}
+	if err := checkInput(append([]string{newWork}, oldWorks...)); err != nil {
+		return "", err
+	}
 
-	if info, err := os.Stat(c.resultPath); err != nil {
-		if info != nil && !info.IsDir() {
-			if err = os.Remove(c.resultPath); err != nil {
-				return "", err
-			}
-		}
+	resultPath, err := c.results.create()
+	if err != nil {
+		return "", err
 	}
 
 	oldWorksStr := strings.Join(oldWorks, ",")
-	cmd := exec.Command("java", "-jar", c.checkerPath, newWork, "-l", "csharp", "-r", c.resultPath, "-old", oldWorksStr)
-	if err := cmd.Run(); err != nil {
+	args := append(c.javaArgs(), "-jar", c.checkerPath, newWork, "-l", c.options.Language, "-r", resultPath, "-old", oldWorksStr)
+
+	start := time.Now()
+	if err = c.execute("java", args, nil, io.Discard, append([]string{newWork}, oldWorks...), resultPath); err != nil {
+		c.Release(resultPath, true)
 		return "", err
 	}
+	c.logger.Debugf("Checker finished in %s", time.Since(start).Round(time.Millisecond))
 
-	return c.resultPath, nil
+	return resultPath, nil
+}
+
+func (c *checkerT) Release(resultPath string, failed bool) {
+	c.results.release(resultPath, failed)
+}
+
+// execute runs the program with the limits and in the sandbox, which allows to write the result.
+// The failure is returned as RunError with the end of the program output.
+func (c *checkerT) execute(name string, args []string, stdin io.Reader, stdout io.Writer, readOnly []string, result string) error {
+	ctx := context.Background()
+	if c.options.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.Timeout)
+		defer cancel()
+	}
+
+	if err := c.sandbox.prepareResult(result); err != nil {
+		return err
+	}
+
+	var writable []string
+	if result != "" {
+		writable = []string{filepath.Dir(result)}
+	}
+
+	cmd, cleanup, err := c.sandbox.command(ctx, name, args, readOnly, writable)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var stdoutTail, stderr tailBuffer
+	cmd.Stdin = stdin
+	cmd.Stdout = io.MultiWriter(stdout, &stdoutTail)
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = killWaitDelay
+
+	if err = cmd.Run(); err != nil {
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		return newRunError(timedOut, err, stdoutTail.String(), stderr.String())
+	}
+	return nil
+}
+
+// checkInput checks, that all works exist, before starting the checker.
+func checkInput(paths []string) error {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return &RunError{Kind: ErrBadInput, ExitCode: -1, Err: err}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,141 @@
+package checker
+
+import (
+	"CodeBorrowing/internal/structure"
+	"CodeBorrowing/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// combinedChecker runs the token-based checker and the structural one.
+// Similarities of results are weighted, matches of both are reported.
+type combinedChecker struct {
+	logger     *logger.Logger
+	token      Checker
+	structural Checker
+	weight     float64 // Share of the structural similarity.
+}
+
+// NewCombinedChecker combines results of the checkers. If the structural checker fails,
+// results of the token-based one are returned as is with ErrPartialResult.
+func NewCombinedChecker(appLogger *logger.Logger, token Checker, structural Checker, weight float64) (Checker, error) {
+	if !(0 <= weight && weight <= 1) {
+		return nil, ErrInvalidStructureWeight
+	}
+
+	return &combinedChecker{
+		logger:     appLogger,
+		token:      token,
+		structural: structural,
+		weight:     weight,
+	}, nil
+}
+
+func (c *combinedChecker) Run(newWork string, oldWorks []string) (string, error) {
+	resultPath, err := c.token.Run(newWork, oldWorks)
+	if err != nil {
+		return "", err
+	}
+
+	structuralPath, err := c.structural.Run(newWork, oldWorks)
+	if err != nil {
+		c.logger.Warnf("Structural comparison failed, token results are used: %v", err)
+		return resultPath, fmt.Errorf("%w: %v", ErrPartialResult, err)
+	}
+	defer c.structural.Release(structuralPath, false)
+
+	if err = c.merge(resultPath, structuralPath); err != nil {
+		c.token.Release(resultPath, true)
+		return "", err
+	}
+	return resultPath, nil
+}
+
+func (c *combinedChecker) Release(resultPath string, failed bool) {
+	c.token.Release(resultPath, failed)
+}
+
+// merge writes combined results to the result of the token-based checker.
+func (c *combinedChecker) merge(resultPath string, structuralPath string) error {
+	tokenResults, err := readComparisons(resultPath)
+	if err != nil {
+		return err
+	}
+	structuralResults, err := readComparisons(structuralPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(combineComparisons(tokenResults, structuralResults, c.weight))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(resultPath, data, 0644)
+}
+
+func readComparisons(resultPath string) ([]structure.Comparison, error) {
+	data, err := os.ReadFile(resultPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var comparisons []structure.Comparison
+	if err = json.Unmarshal(data, &comparisons); err != nil {
+		return nil, err
+	}
+	return comparisons, nil
+}
+
+// combineComparisons weights similarities of the same pairs of works.
+// A pair missing in results of one checker has zero similarity there.
+func combineComparisons(token []structure.Comparison, structural []structure.Comparison, weight float64) []structure.Comparison {
+	combined := make([]structure.Comparison, 0, len(token)+len(structural))
+	byPair := make(map[[2]string]int, len(token))
+
+	for _, comparison := range token {
+		byPair[pairKey(comparison.ID1, comparison.ID2)] = len(combined)
+		comparison.Similarities = weightSimilarities(comparison.Similarities, 1-weight)
+		combined = append(combined, comparison)
+	}
+
+	for _, comparison := range structural {
+		similarities := weightSimilarities(comparison.Similarities, weight)
+
+		i, ok := byPair[pairKey(comparison.ID1, comparison.ID2)]
+		if !ok {
+			comparison.Similarities = similarities
+			combined = append(combined, comparison)
+			continue
+		}
+
+		target := &combined[i]
+		if workName(target.ID1) != workName(comparison.ID1) {
+			comparison = comparison.Swap()
+		}
+		target.Similarities.Avg += similarities.Avg
+		target.Similarities.Max += similarities.Max
+		target.Matches = append(target.Matches, comparison.Matches...)
+	}
+
+	return combined
+}
+
+func weightSimilarities(similarities structure.Similarities, weight float64) structure.Similarities {
+	return structure.Similarities{Avg: similarities.Avg * weight, Max: similarities.Max * weight}
+}
+
+// pairKey identifies the pair of works in any order. Checkers give ids as names or paths of works.
+func pairKey(id1 string, id2 string) [2]string {
+	name1, name2 := workName(id1), workName(id2)
+	if name1 > name2 {
+		name1, name2 = name2, name1
+	}
+	return [2]string{name1, name2}
+}
+
+func workName(id string) string {
+	return path.Base(strings.ReplaceAll(id, "\\", "/"))
+}
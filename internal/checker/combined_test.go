@@ -0,0 +1,71 @@
+package checker
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stubChecker writes the same comparisons on each run or fails.
+type stubChecker struct {
+	dir         string
+	comparisons string
+	err         error
+}
+
+func (c *stubChecker) Run(string, []string) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	resultPath := filepath.Join(c.dir, "result.json")
+	return resultPath, os.WriteFile(resultPath, []byte(c.comparisons), 0644)
+}
+
+func (c *stubChecker) Release(string, bool) {}
+
+func TestCombinedCheckerRun(t *testing.T) {
+	token := `[{"id1": "a", "id2": "b", "similarities": {"AVG": 0.5, "MAX": 0.5}}]`
+	structural := `[{"id1": "b", "id2": "a", "similarities": {"AVG": 1, "MAX": 1}}]`
+
+	tests := []struct {
+		name          string
+		structuralErr error
+		wantErr       error
+		wantAvg       float64
+	}{
+		{"both", nil, nil, 0.75},
+		{"structural failed", errors.New("parse failed"), ErrPartialResult, 0.5},
+	}
+
+	appLogger := &logger.Logger{Logger: *logrus.New()}
+	appLogger.SetOutput(io.Discard)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCombinedChecker(appLogger,
+				&stubChecker{dir: t.TempDir(), comparisons: token},
+				&stubChecker{dir: t.TempDir(), comparisons: structural, err: tt.structuralErr}, 0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resultPath, err := c.Run("a", []string{"b"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+
+			comparisons, err := readComparisons(resultPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(comparisons) != 1 || comparisons[0].Similarities.Avg != tt.wantAvg {
+				t.Errorf("got %+v, want one comparison with avg %v", comparisons, tt.wantAvg)
+			}
+		})
+	}
+}
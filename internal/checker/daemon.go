@@ -0,0 +1,306 @@
+package checker
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Protocol of the checker daemon.
+//
+// The daemon reads jobs from stdin and writes results to stdout, one JSON object per line:
+//
+//	-> {"id": 1, "new_work": "/works/1", "old_works": ["/works/2"], "language": "csharp", "result": "/results/run-1/result.json"}
+//	<- {"id": 1}
+//	<- {"id": 2, "error": "cannot parse /works/3/3/a.cs", "kind": "bad_input"}
+//
+// The result is written to the file in the same format as by a single run. Kinds of errors are
+// "timeout", "out_of_memory" and "bad_input", other errors have no kind. Lines of stdout, which are
+// not JSON, are ignored. The daemon must exit, when stdin is closed.
+
+// daemonResponseLimit is the max length of a line of the daemon output.
+const daemonResponseLimit = 1024 * 1024
+
+var (
+	errDaemonExited  = errors.New("checker daemon exited")
+	errDaemonTimeout = errors.New("checker daemon did not answer in time")
+)
+
+// DaemonOptions describe the warm checker process.
+type DaemonOptions struct {
+	Args    []string // Arguments of the checker, which start it as a daemon.
+	MaxJobs uint64   // The daemon is restarted after this count of jobs (0 - never).
+}
+
+type daemonRequest struct {
+	ID       uint64   `json:"id"`
+	NewWork  string   `json:"new_work"`
+	OldWorks []string `json:"old_works"`
+	Language string   `json:"language"`
+	Result   string   `json:"result"`
+}
+
+type daemonResponse struct {
+	ID    uint64 `json:"id"`
+	Error string `json:"error,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+}
+
+// daemonProcess is one started daemon.
+type daemonProcess struct {
+	stdin     io.WriteCloser
+	stdout    tailBuffer // Lines, which are not responses.
+	stderr    tailBuffer
+	responses chan daemonResponse
+	done      chan struct{} // Closed, when the process has exited.
+	err       error         // Result of the process, valid after done.
+	kill      func() error
+	jobs      uint64
+}
+
+// daemonChecker keeps one checker process and sends it jobs,
+// so the JVM starts once for many tasks.
+//
+// The daemon can not run in the sandbox: directories of works could not be mounted read-only
+// for each job and limits would apply to all jobs together.
+type daemonChecker struct {
+	checkerT
+	daemon DaemonOptions
+
+	mu     sync.Mutex
+	proc   *daemonProcess
+	lastID uint64
+}
+
+func NewDaemonChecker(appLogger *logger.Logger, checker string, results string, options Options, daemon DaemonOptions) Checker {
+	return &daemonChecker{
+		checkerT: *newCheckerT(appLogger, checker, results, options),
+		daemon:   daemon,
+	}
+}
+
+func (d *daemonChecker) Run(newWork string, oldWorks []string) (string, error) {
+	if newWork == "" || len(oldWorks) == 0 {
+		return "", ErrNoFiles
+	}
+	if err := checkInput(append([]string{newWork}, oldWorks...)); err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	resultPath, err := d.results.create()
+	if err != nil {
+		return "", err
+	}
+	if err = d.sandbox.prepareResult(resultPath); err != nil {
+		d.Release(resultPath, true)
+		return "", err
+	}
+
+	if err = d.send(newWork, oldWorks, resultPath); err != nil {
+		d.Release(resultPath, true)
+		return "", err
+	}
+	return resultPath, nil
+}
+
+// send gives the job to the daemon and waits for the answer.
+func (d *daemonChecker) send(newWork string, oldWorks []string, resultPath string) error {
+	proc, err := d.process()
+	if err != nil {
+		return err
+	}
+
+	d.lastID++
+	request := daemonRequest{
+		ID:       d.lastID,
+		NewWork:  newWork,
+		OldWorks: oldWorks,
+		Language: d.options.Language,
+		Result:   resultPath,
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if _, err = proc.stdin.Write(append(data, '\n')); err != nil {
+		d.stop()
+		return newRunError(false, err, proc.stdout.String(), proc.stderr.String())
+	}
+
+	var timeout <-chan time.Time
+	if d.options.Timeout != 0 {
+		timer := time.NewTimer(d.options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case response := <-proc.responses:
+			if response.ID != request.ID {
+				continue
+			}
+			d.finishJob(proc)
+
+			if response.Error != "" {
+				return &RunError{
+					Kind:     responseKind(response.Kind),
+					ExitCode: -1,
+					Stderr:   proc.stderr.String(),
+					Err:      errors.New(response.Error),
+				}
+			}
+			d.logger.Debugf("Checker daemon finished job %d in %s", request.ID, time.Since(start).Round(time.Millisecond))
+
+			return nil
+
+		case <-proc.done:
+			d.proc = nil
+			err = proc.err
+			if err == nil {
+				err = errDaemonExited
+			}
+			return newRunError(false, err, proc.stdout.String(), proc.stderr.String())
+
+		case <-timeout:
+			d.kill()
+			return newRunError(true, errDaemonTimeout, proc.stdout.String(), proc.stderr.String())
+		}
+	}
+}
+
+func responseKind(kind string) error {
+	switch kind {
+	case "timeout":
+		return ErrTimeout
+	case "out_of_memory":
+		return ErrOutOfMemory
+	case "bad_input":
+		return ErrBadInput
+	}
+	return ErrFailed
+}
+
+// finishJob restarts the daemon, which has done enough jobs.
+func (d *daemonChecker) finishJob(proc *daemonProcess) {
+	proc.jobs++
+	if d.daemon.MaxJobs != 0 && proc.jobs >= d.daemon.MaxJobs {
+		d.logger.Infof("Checker daemon is restarted after %d jobs", proc.jobs)
+		d.stop()
+	}
+}
+
+// process returns the running daemon and starts a new one, if it is not running.
+func (d *daemonChecker) process() (*daemonProcess, error) {
+	if d.proc != nil {
+		select {
+		case <-d.proc.done:
+			d.logger.Warnf("Checker daemon exited: %v", d.proc.err)
+			d.proc = nil
+		default:
+			return d.proc, nil
+		}
+	}
+
+	args := append(d.javaArgs(), "-jar", d.checkerPath)
+	args = append(args, d.daemon.Args...)
+
+	cmd, cleanup, err := d.sandbox.command(context.Background(), "java", args, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	proc := &daemonProcess{
+		responses: make(chan daemonResponse, 1),
+		done:      make(chan struct{}),
+	}
+	cmd.Stderr = &proc.stderr
+
+	if proc.stdin, err = cmd.StdinPipe(); err != nil {
+		cleanup()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		cleanup()
+		return nil, err
+	}
+	proc.kill = cmd.Process.Kill
+
+	// Responses are read until the daemon closes stdout, then the process is waited.
+	go func() {
+		proc.read(stdout)
+		proc.err = cmd.Wait()
+		cleanup()
+		close(proc.done)
+	}()
+
+	d.logger.Infof("Checker daemon started, pid %d", cmd.Process.Pid)
+	d.proc = proc
+	return proc, nil
+}
+
+func (p *daemonProcess) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), daemonResponseLimit)
+
+	for scanner.Scan() {
+		var response daemonResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil || response.ID == 0 {
+			_, _ = p.stdout.Write(append(scanner.Bytes(), '\n'))
+			continue
+		}
+
+		// The response to a job, which is not waited anymore, is dropped.
+		select {
+		case <-p.responses:
+		default:
+		}
+		p.responses <- response
+	}
+
+	_, _ = io.Copy(io.Discard, stdout)
+}
+
+// stop asks the daemon to exit by closing stdin and kills it, if it does not exit in time.
+func (d *daemonChecker) stop() {
+	proc := d.proc
+	if proc == nil {
+		return
+	}
+	d.proc = nil
+
+	_ = proc.stdin.Close()
+	select {
+	case <-proc.done:
+		return
+	case <-time.After(killWaitDelay):
+	}
+
+	_ = proc.kill()
+	<-proc.done
+}
+
+// kill stops the daemon at once, when it does not answer.
+func (d *daemonChecker) kill() {
+	if d.proc == nil {
+		return
+	}
+	_ = d.proc.kill()
+	<-d.proc.done
+	d.proc = nil
+}
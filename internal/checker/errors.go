@@ -0,0 +1,155 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Exit codes of the sandbox, when the checker is killed by SIGKILL or SIGXCPU.
+const (
+	exitKilled   = 128 + int(syscall.SIGKILL)
+	exitCPULimit = 128 + int(cpuLimitSignal)
+)
+
+// outputLimit is the count of last bytes of stdout and stderr kept for errors.
+const outputLimit = 16 * 1024
+
+// Kinds of checker failures.
+var (
+	ErrTimeout     = errors.New("checker timed out")
+	ErrOutOfMemory = errors.New("checker ran out of memory")
+	ErrBadInput    = errors.New("checker rejected the input")
+	ErrFailed      = errors.New("checker failed")
+)
+
+var ErrDaemonSandbox = errors.New("checker daemon can not run in the sandbox")
+
+// Output of the JVM and the checker, which shows the kind of the failure.
+var (
+	outOfMemoryMarkers = []string{
+		"java.lang.OutOfMemoryError",
+		"insufficient memory for the Java Runtime Environment",
+		"Could not reserve enough space",
+	}
+	badInputMarkers = []string{
+		"FileNotFoundException",
+		"NoSuchFileException",
+		"IllegalArgumentException",
+		"MalformedInputException",
+		"ParseException",
+		"Usage:",
+	}
+)
+
+// RunError is a failure of the checker process with its output.
+// errors.Is matches it with its kind.
+type RunError struct {
+	Kind     error
+	ExitCode int // -1, if the process did not exit by itself.
+	Stdout   string
+	Stderr   string
+	Err      error
+}
+
+func (e *RunError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v: %v", e.Kind, e.Err)
+	if e.Stderr != "" {
+		fmt.Fprintf(&b, "\nstderr:\n%s", strings.TrimRight(e.Stderr, "\n"))
+	}
+	if e.Stdout != "" {
+		fmt.Fprintf(&b, "\nstdout:\n%s", strings.TrimRight(e.Stdout, "\n"))
+	}
+	return b.String()
+}
+
+func (e *RunError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func newRunError(timedOut bool, err error, stdout string, stderr string) *RunError {
+	runErr := &RunError{Kind: ErrFailed, ExitCode: -1, Stdout: stdout, Stderr: stderr, Err: err}
+
+	var exitErr *exec.ExitError
+	var signal syscall.Signal
+	if errors.As(err, &exitErr) {
+		runErr.ExitCode = exitErr.ExitCode()
+		signal = exitSignal(exitErr)
+	}
+
+	output := stderr + stdout
+	switch {
+	case timedOut, signal == cpuLimitSignal:
+		runErr.Kind = ErrTimeout
+	case containsAny(output, outOfMemoryMarkers):
+		runErr.Kind = ErrOutOfMemory
+	case signal == syscall.SIGKILL:
+		// Killed not by us, usually by the OOM killer of the system.
+		runErr.Kind = ErrOutOfMemory
+	case signal != 0:
+		// Other signals are crashes of the checker, whatever it printed before.
+		runErr.Kind = ErrFailed
+	case containsAny(output, badInputMarkers):
+		runErr.Kind = ErrBadInput
+	}
+
+	return runErr
+}
+
+// exitSignal returns the signal, which killed the process or the checker inside the sandbox (0 - none).
+func exitSignal(exitErr *exec.ExitError) syscall.Signal {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal()
+	}
+
+	switch exitErr.ExitCode() {
+	case exitKilled:
+		return syscall.SIGKILL
+	case exitCPULimit:
+		return cpuLimitSignal
+	}
+	return 0
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// tailBuffer keeps the last outputLimit bytes written to it.
+// It may be written by the process and read at the same time.
+type tailBuffer struct {
+	mu        sync.Mutex
+	data      []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if len(b.data) > outputLimit {
+		b.data = append(b.data[:0], b.data[len(b.data)-outputLimit:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated {
+		return "..." + string(b.data)
+	}
+	return string(b.data)
+}
@@ -0,0 +1,47 @@
+package checker
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestNewRunError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported")
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		timedOut bool
+		stderr   string
+		want     error
+	}{
+		{"killed", "kill -KILL $$", false, "", ErrOutOfMemory},
+		{"cpu limit", "kill -XCPU $$", false, "", ErrTimeout},
+		{"crashed", "kill -SEGV $$", false, "", ErrFailed},
+		{"crashed after usage", "kill -ABRT $$", false, "Usage: checker", ErrFailed},
+		{"terminated on timeout", "kill -TERM $$", true, "", ErrTimeout},
+		{"sandbox killed", "exit 137", false, "", ErrOutOfMemory},
+		{"sandbox cpu limit", "exit 152", false, "", ErrTimeout},
+		{"out of memory", "exit 3", false, "java.lang.OutOfMemoryError: Java heap space", ErrOutOfMemory},
+		{"bad input", "exit 1", false, "java.nio.file.NoSuchFileException: a", ErrBadInput},
+		{"failed", "exit 1", false, "", ErrFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exec.Command("sh", "-c", tt.script).Run()
+			if err == nil {
+				t.Fatal("script succeeded")
+			}
+
+			runErr := newRunError(tt.timedOut, err, "", tt.stderr)
+			if !errors.Is(runErr, tt.want) {
+				t.Errorf("newRunError() kind = %v, want %v", runErr.Kind, tt.want)
+			}
+		})
+	}
+}
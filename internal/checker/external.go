@@ -0,0 +1,120 @@
+package checker
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// Protocol of an external checker.
+//
+// The checker is started for each task with the configured arguments. It reads one request
+// from stdin and writes one response to stdout:
+//
+//	-> {"new_work": "/works/1", "old_works": ["/works/2", "/works/3"], "language": "csharp", "options": {"min_tokens": "12"}}
+//	<- {"results": [{"id1": "1", "id2": "2", "similarities": {"AVG": 0.4, "MAX": 0.5}, "matches": [...]}]}
+//
+// Results have the shape of task.ResultDTO: ids are names of work directories, similarities
+// are fractions from 0 to 1, files of matches are relative to work directories, lines start from 1.
+// The failed checker answers {"error": "...", "kind": "bad_input"} with kinds as of the daemon
+// protocol or exits with a non-zero code.
+
+var ErrNoCommand = errors.New("external checker command is not set")
+
+// ExternalOptions describe the external checker.
+type ExternalOptions struct {
+	Command string            // Executable of the checker.
+	Args    []string          // Arguments of the executable.
+	Options map[string]string // Options of the checker passed in requests as is.
+}
+
+type externalRequest struct {
+	NewWork  string            `json:"new_work"`
+	OldWorks []string          `json:"old_works"`
+	Language string            `json:"language"`
+	Options  map[string]string `json:"options,omitempty"`
+}
+
+type externalResponse struct {
+	Results json.RawMessage `json:"results"`
+	Error   string          `json:"error,omitempty"`
+	Kind    string          `json:"kind,omitempty"`
+}
+
+// externalChecker runs any executable, which follows the protocol,
+// and writes results of its response to the result file.
+type externalChecker struct {
+	checkerT
+	external ExternalOptions
+}
+
+func NewExternalChecker(appLogger *logger.Logger, results string, options Options, external ExternalOptions) (Checker, error) {
+	if external.Command == "" {
+		return nil, ErrNoCommand
+	}
+
+	return &externalChecker{
+		checkerT: *newCheckerT(appLogger, external.Command, results, options),
+		external: external,
+	}, nil
+}
+
+func (c *externalChecker) Run(newWork string, oldWorks []string) (string, error) {
+	if newWork == "" || len(oldWorks) == 0 {
+		return "", ErrNoFiles
+	}
+	if err := checkInput(append([]string{newWork}, oldWorks...)); err != nil {
+		return "", err
+	}
+
+	request, err := json.Marshal(externalRequest{
+		NewWork:  newWork,
+		OldWorks: oldWorks,
+		Language: c.options.Language,
+		Options:  c.external.Options,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var stdout bytes.Buffer
+	start := time.Now()
+	err = c.execute(c.checkerPath, c.external.Args, bytes.NewReader(request), &stdout, append([]string{newWork}, oldWorks...), "")
+	if err != nil {
+		return "", err
+	}
+
+	var response externalResponse
+	if err = json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return "", &RunError{Kind: ErrFailed, Stdout: tail(stdout.String()), Err: err}
+	}
+	if response.Error != "" {
+		return "", &RunError{Kind: responseKind(response.Kind), Err: errors.New(response.Error)}
+	}
+
+	results := response.Results
+	if len(results) == 0 || string(results) == "null" {
+		results = json.RawMessage("[]")
+	}
+	resultPath, err := c.results.create()
+	if err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(resultPath, results, 0644); err != nil {
+		c.Release(resultPath, true)
+		return "", err
+	}
+	c.logger.Debugf("External checker finished in %s", time.Since(start).Round(time.Millisecond))
+
+	return resultPath, nil
+}
+
+// tail returns the end of the output as kept for errors.
+func tail(output string) string {
+	var buffer tailBuffer
+	_, _ = buffer.Write([]byte(output))
+	return buffer.String()
+}
@@ -0,0 +1,65 @@
+package checker
+
+import (
+	"CodeBorrowing/internal/structure"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ResultKey identifies results of the backend with the config: the backend, its version
+// and settings, which change results. Limits of resources are not a part of the key.
+// Versions of the jar and external executables are hashes of their files.
+func ResultKey(backend string, config BackendConfig) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", backend, config.Options.Language)
+	fmt.Fprintf(h, "structure %d %d %g\n", structure.Version, config.Structure.MinTokens, config.Structure.Weight)
+
+	switch backend {
+	case BackendProcess:
+		if err := hashFile(h, config.Path); err != nil {
+			return "", err
+		}
+	case BackendDaemon:
+		if err := hashFile(h, config.Path); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", strings.Join(config.Daemon.Args, "\x00"))
+	case BackendExternal:
+		command, err := exec.LookPath(config.External.Command)
+		if err != nil {
+			return "", err
+		}
+		if err = hashFile(h, command); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", strings.Join(config.External.Args, "\x00"))
+
+		names := make([]string, 0, len(config.External.Options))
+		for name := range config.External.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(h, "%s=%s\n", name, config.External.Options[name])
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,52 @@
+package checker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResultKey(t *testing.T) {
+	jar := filepath.Join(t.TempDir(), "checker.jar")
+	if err := os.WriteFile(jar, []byte("jar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := BackendConfig{Path: jar, Options: Options{Language: "java"}, Daemon: DaemonOptions{Args: []string{"--daemon"}}}
+
+	key := func(backend string, config BackendConfig) string {
+		t.Helper()
+		k, err := ResultKey(backend, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	daemonArgs := base
+	daemonArgs.Daemon.Args = []string{"--daemon", "--threshold=0.3"}
+	jobs := base
+	jobs.Daemon.MaxJobs = 100
+	heap := base
+	heap.Options.MaxHeap = 512
+
+	tests := []struct {
+		name    string
+		backend string
+		config  BackendConfig
+		same    bool
+	}{
+		{"daemon args", BackendDaemon, daemonArgs, false},
+		{"daemon restarts", BackendDaemon, jobs, true},
+		{"resource limits", BackendDaemon, heap, true},
+		{"process ignores daemon args", BackendProcess, daemonArgs, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			same := key(tt.backend, base) == key(tt.backend, tt.config)
+			if same != tt.same {
+				t.Errorf("keys are the same = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
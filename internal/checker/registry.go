@@ -0,0 +1,83 @@
+package checker
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Names of built-in backends.
+const (
+	BackendProcess  = "process"  // The jar in a new process for each task.
+	BackendDaemon   = "daemon"   // The jar in one warm process for all tasks.
+	BackendExternal = "external" // Any executable following the external protocol.
+	BackendAST      = "ast"      // Comparison of syntax trees inside the worker.
+)
+
+// BackendConfig contains settings of all backends, each backend takes its own.
+type BackendConfig struct {
+	Path      string // Path of the jar.
+	Results   string // Directory, where runs keep their results.
+	Options   Options
+	Daemon    DaemonOptions
+	External  ExternalOptions
+	Structure StructureOptions
+}
+
+// Factory creates the checker of the backend.
+type Factory func(appLogger *logger.Logger, config BackendConfig) (Checker, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		BackendProcess: func(appLogger *logger.Logger, config BackendConfig) (Checker, error) {
+			return NewChecker(appLogger, config.Path, config.Results, config.Options), nil
+		},
+		BackendDaemon: func(appLogger *logger.Logger, config BackendConfig) (Checker, error) {
+			if config.Options.Sandbox.Enabled {
+				return nil, ErrDaemonSandbox
+			}
+			return NewDaemonChecker(appLogger, config.Path, config.Results, config.Options, config.Daemon), nil
+		},
+		BackendExternal: func(appLogger *logger.Logger, config BackendConfig) (Checker, error) {
+			return NewExternalChecker(appLogger, config.Results, config.Options, config.External)
+		},
+		BackendAST: func(appLogger *logger.Logger, config BackendConfig) (Checker, error) {
+			return NewStructureChecker(appLogger, config.Results, config.Options, config.Structure), nil
+		},
+	}
+)
+
+// Register adds the backend or replaces the one with the same name.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = factory
+}
+
+// Backends returns names of registered backends.
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the checker of the backend with the name.
+func New(name string, appLogger *logger.Logger, config BackendConfig) (Checker, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown checker backend \"%s\", available: %v", name, Backends())
+	}
+	return factory(appLogger, config)
+}
@@ -0,0 +1,13 @@
+package checker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewDaemonInSandbox(t *testing.T) {
+	config := BackendConfig{Path: "checker.jar", Options: Options{Sandbox: SandboxOptions{Enabled: true}}}
+	if _, err := New(BackendDaemon, nil, config); !errors.Is(err, ErrDaemonSandbox) {
+		t.Errorf("New() error = %v, want %v", err, ErrDaemonSandbox)
+	}
+}
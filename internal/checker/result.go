@@ -0,0 +1,77 @@
+package checker
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	runDirPrefix    = "run-"
+	failedDirPrefix = "failed-"
+	resultFileName  = "result.json"
+)
+
+// resultDirs gives each run its own directory for results, so runs never share files.
+// Directories of failed runs are kept for debugging, if it is configured.
+type resultDirs struct {
+	logger     *logger.Logger
+	root       string
+	keepFailed bool
+}
+
+// newResultDirs removes directories of runs left by a stopped worker.
+func newResultDirs(appLogger *logger.Logger, root string, keepFailed bool) *resultDirs {
+	r := &resultDirs{logger: appLogger, root: root, keepFailed: keepFailed}
+
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		appLogger.Error(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), runDirPrefix) {
+			if err = os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+				appLogger.Error(err)
+			}
+		}
+	}
+
+	return r
+}
+
+// create returns the path of the result file in a new directory.
+func (r *resultDirs) create() (string, error) {
+	if err := os.MkdirAll(r.root, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp(r.root, runDirPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, resultFileName), nil
+}
+
+// release removes the directory of the result or keeps it, if the run failed.
+func (r *resultDirs) release(resultPath string, failed bool) {
+	dir := filepath.Dir(resultPath)
+	if filepath.Dir(dir) != filepath.Clean(r.root) || !strings.HasPrefix(filepath.Base(dir), runDirPrefix) {
+		r.logger.Errorf("Result \"%s\" is not managed by the checker", resultPath)
+		return
+	}
+
+	if failed && r.keepFailed {
+		kept := filepath.Join(r.root, failedDirPrefix+strings.TrimPrefix(filepath.Base(dir), runDirPrefix))
+		if err := os.Rename(dir, kept); err != nil {
+			r.logger.Error(err)
+			return
+		}
+		r.logger.Warnf("Results of the failed run are kept in %s", kept)
+		return
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		r.logger.Error(err)
+	}
+}
@@ -0,0 +1,39 @@
+package checker
+
+// sandboxEnv marks the process started as the sandbox of the checker.
+const sandboxEnv = "CODE_BORROWING_SANDBOX"
+
+// sandboxFailure is the exit code of the sandbox, which could not be set up.
+const sandboxFailure = 125
+
+// SandboxOptions restrict the checker process.
+type SandboxOptions struct {
+	Enabled   bool
+	Root      string // Storage directory, which the checker may only read (empty - only submissions are read-only).
+	User      string // Name of the user running the checker, requires root and access to works (empty - the current user).
+	MaxCPU    uint64 // CPU time in seconds (0 - unlimited).
+	MaxMemory uint64 // Address space in megabytes (0 - unlimited), the JVM reserves much more than the heap.
+	MaxFiles  uint64 // Count of open files (0 - unlimited).
+	TempSize  uint64 // Size of the private temp directory in megabytes.
+}
+
+// sandboxSpec describes the environment of one checker run for the sandbox process.
+type sandboxSpec struct {
+	Namespaces bool     `json:"namespaces"` // Mount namespace is available.
+	ReadOnly   []string `json:"read_only"`  // The storage and directories of submissions.
+	Writable   []string `json:"writable"`   // Directories of results inside read-only ones.
+	Temp       string   `json:"temp"`       // Private temp directory.
+	TempSize   uint64   `json:"temp_size"`
+
+	SetUser bool   `json:"set_user"`
+	Uid     uint32 `json:"uid"`
+	Gid     uint32 `json:"gid"`
+
+	MaxCPU    uint64 `json:"max_cpu"`
+	MaxMemory uint64 `json:"max_memory"`
+	MaxFiles  uint64 `json:"max_files"`
+
+	Probe bool     `json:"probe"` // Exit after the setup.
+	Path  string   `json:"path"`
+	Args  []string `json:"args"`
+}
@@ -0,0 +1,363 @@
+package checker
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sandboxSpecFd is the descriptor of the inherited spec, the first one after stderr.
+const sandboxSpecFd = 3
+
+// cpuLimitSignal is sent to the checker, which exceeds the soft CPU limit.
+const cpuLimitSignal = syscall.SIGXCPU
+
+// javaToolOptions is the environment variable with options of the JVM.
+const javaToolOptions = "JAVA_TOOL_OPTIONS"
+
+// Flags of a mount, which must be kept, when it is remounted read-only.
+const lockedMountFlags = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+	syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+
+// sandbox starts the checker through this executable, which sets up
+// namespaces, mounts, limits and the user before starting the checker itself.
+type sandbox struct {
+	options    SandboxOptions
+	namespaces bool
+	setUser    bool
+	uid, gid   uint32
+}
+
+// IsSandboxProcess reports, whether the process is started as the sandbox of the checker.
+func IsSandboxProcess() bool {
+	return os.Getenv(sandboxEnv) != ""
+}
+
+// newSandbox checks, what the system allows, and falls back to limits only
+// or to no sandbox at all with a warning.
+func newSandbox(appLogger *logger.Logger, options SandboxOptions) *sandbox {
+	if !options.Enabled {
+		return nil
+	}
+
+	s := &sandbox{options: options, namespaces: true}
+
+	if options.User != "" {
+		if os.Getuid() != 0 {
+			appLogger.Warnf("Sandbox user \"%s\" requires root, the checker runs as the current user", options.User)
+		} else if err := s.lookupUser(options.User); err != nil {
+			appLogger.Warnf("Sandbox user \"%s\" is not found, the checker runs as the current user: %v", options.User, err)
+		}
+	}
+
+	err := s.probe()
+	if err == nil {
+		return s
+	}
+	appLogger.Warnf("Namespaces are not available, the checker runs with resource limits only: %v", err)
+
+	s.namespaces = false
+	if err = s.probe(); err == nil {
+		return s
+	}
+	appLogger.Warnf("Sandbox is not available, the checker runs without it: %v", err)
+	return nil
+}
+
+func (s *sandbox) lookupUser(name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return err
+	}
+
+	s.setUser, s.uid, s.gid = true, uint32(uid), uint32(gid)
+	return nil
+}
+
+// prepareResult gives the directory of the result to the user of the checker.
+func (s *sandbox) prepareResult(resultPath string) error {
+	if s == nil || !s.setUser || resultPath == "" {
+		return nil
+	}
+	return os.Chown(filepath.Dir(resultPath), int(s.uid), int(s.gid))
+}
+
+// probe starts the sandbox, which only sets up the environment and exits.
+func (s *sandbox) probe() error {
+	temp, err := os.MkdirTemp("", "sandbox-probe-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temp)
+
+	result := filepath.Join(temp, "result")
+	if err = os.Mkdir(result, 0o755); err != nil {
+		return err
+	}
+
+	spec := sandboxSpec{Probe: true, ReadOnly: []string{temp}, Writable: []string{result}, Temp: temp}
+	cmd, closeSpec, err := s.start(context.Background(), spec)
+	if err != nil {
+		return err
+	}
+	defer closeSpec()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return nil
+}
+
+// command returns the command, which runs the program in the sandbox,
+// and the function, which removes the private temp directory after the run.
+// The storage and directories of submissions are read-only, only directories of results stay writable.
+func (s *sandbox) command(ctx context.Context, name string, args []string, readOnly []string, writable []string) (*exec.Cmd, func(), error) {
+	if s == nil {
+		return exec.CommandContext(ctx, name, args...), func() {}, nil
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, nil, err
+	}
+
+	temp, err := os.MkdirTemp("", "checker-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.options.Root != "" {
+		readOnly = append([]string{s.options.Root}, readOnly...)
+	}
+
+	spec := sandboxSpec{Path: path, Args: args, ReadOnly: readOnly, Writable: writable, Temp: temp}
+	cmd, closeSpec, err := s.start(ctx, spec)
+	if err != nil {
+		_ = os.RemoveAll(temp)
+		return nil, nil, err
+	}
+	return cmd, func() { closeSpec(); _ = os.RemoveAll(temp) }, nil
+}
+
+// start returns the command of the sandbox and the function, which closes the file of the spec.
+// The spec is passed as an inherited file, because arguments and the environment are limited in size.
+func (s *sandbox) start(ctx context.Context, spec sandboxSpec) (*exec.Cmd, func(), error) {
+	spec.Namespaces = s.namespaces
+	spec.TempSize = s.options.TempSize
+	spec.SetUser, spec.Uid, spec.Gid = s.setUser, s.uid, s.gid
+	spec.MaxCPU, spec.MaxMemory, spec.MaxFiles = s.options.MaxCPU, s.options.MaxMemory, s.options.MaxFiles
+
+	file, err := specFile(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Env = append(os.Environ(), sandboxEnv+"=1")
+	cmd.ExtraFiles = []*os.File{file}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+
+	if s.namespaces {
+		cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+
+		// Without root the namespaces are owned by a new user namespace,
+		// where the current user is root.
+		if os.Getuid() != 0 {
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+			cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+			cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+		}
+	}
+
+	return cmd, func() { _ = file.Close() }, nil
+}
+
+// specFile writes the spec to an unlinked temp file, which is read from the start.
+func specFile(spec sandboxSpec) (*os.File, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "sandbox-spec-")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(file.Name())
+
+	if _, err = file.Write(data); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// RunSandboxProcess sets up the sandbox described in the inherited spec, runs the checker
+// and exits with its code. The checker killed by a signal gives 128 + signal.
+func RunSandboxProcess() {
+	file := os.NewFile(sandboxSpecFd, "sandbox-spec")
+	var spec sandboxSpec
+	if err := json.NewDecoder(file).Decode(&spec); err != nil {
+		sandboxExit(fmt.Errorf("spec: %w", err))
+	}
+	_ = file.Close()
+	if err := setupSandbox(spec); err != nil {
+		sandboxExit(err)
+	}
+	if spec.Probe {
+		os.Exit(0)
+	}
+
+	// The sandbox stays the init of the PID namespace, because the init ignores
+	// signals of resource limits and could not be stopped by them.
+	cmd := exec.Command(spec.Path, spec.Args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = sandboxEnviron(spec.Temp)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	if spec.SetUser {
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: spec.Uid, Gid: spec.Gid, Groups: []uint32{}}
+	}
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		if err != nil {
+			sandboxExit(err)
+		}
+		os.Exit(0)
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		os.Exit(128 + int(status.Signal()))
+	}
+	os.Exit(exitErr.ExitCode())
+}
+
+func sandboxExit(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(sandboxFailure)
+}
+
+// sandboxEnviron returns the environment of the checker without the mark of the sandbox
+// and with the private temp directory.
+func sandboxEnviron(temp string) []string {
+	javaOptions := "-Djava.io.tmpdir=" + temp
+	if value := os.Getenv(javaToolOptions); value != "" {
+		javaOptions = value + " " + javaOptions
+	}
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, item := range os.Environ() {
+		name, _, _ := strings.Cut(item, "=")
+		if name != sandboxEnv && name != javaToolOptions && name != "TMPDIR" {
+			env = append(env, item)
+		}
+	}
+	return append(env, "TMPDIR="+temp, javaToolOptions+"="+javaOptions)
+}
+
+func setupSandbox(spec sandboxSpec) error {
+	if spec.Namespaces {
+		if err := setupMounts(spec); err != nil {
+			return err
+		}
+	}
+
+	// The hard CPU limit is a second later, so the checker gets SIGXCPU before SIGKILL.
+	limits := []struct {
+		resource int
+		value    uint64
+		grace    uint64
+	}{
+		{syscall.RLIMIT_CPU, spec.MaxCPU, 1},
+		{syscall.RLIMIT_AS, spec.MaxMemory * 1024 * 1024, 0},
+		{syscall.RLIMIT_NOFILE, spec.MaxFiles, 0},
+	}
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+		rlimit := syscall.Rlimit{Cur: limit.value, Max: limit.value + limit.grace}
+		if err := syscall.Setrlimit(limit.resource, &rlimit); err != nil {
+			return fmt.Errorf("rlimit %d: %w", limit.resource, err)
+		}
+	}
+
+	return nil
+}
+
+// setupMounts makes the storage and directories of submissions read-only and the temp directory private.
+// The temp directory is a new file system, so the host one stays as it is.
+func setupMounts(spec sandboxSpec) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("private mounts: %w", err)
+	}
+
+	// A bind mount copies flags of its source, so writable directories are mounted
+	// before their parents become read-only, which affects the parent mount only.
+	for _, dir := range spec.Writable {
+		if err := syscall.Mount(dir, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("writable %s: %w", dir, err)
+		}
+	}
+
+	for _, dir := range spec.ReadOnly {
+		if err := mountReadOnly(dir); err != nil {
+			return fmt.Errorf("read-only %s: %w", dir, err)
+		}
+	}
+
+	options := "mode=1777"
+	if spec.TempSize != 0 {
+		options = fmt.Sprintf("%s,size=%dm", options, spec.TempSize)
+	}
+	if err := syscall.Mount("tmpfs", spec.Temp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, options); err != nil {
+		return fmt.Errorf("private temp: %w", err)
+	}
+
+	// Processes of the host stay visible, if the kernel does not allow a new proc.
+	_ = syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+
+	return nil
+}
+
+func mountReadOnly(dir string) error {
+	if err := syscall.Mount(dir, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return err
+	}
+
+	// Flags of the statfs have the same values as the mount ones.
+	flags := uintptr(stat.Flags) & lockedMountFlags
+	return syscall.Mount("", dir, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|flags, "")
+}
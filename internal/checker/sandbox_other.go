@@ -0,0 +1,39 @@
+//go:build !linux
+
+package checker
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// cpuLimitSignal is SIGXCPU of BSD systems, which is not defined on all systems.
+// Limits are not set without the sandbox, so the checker gets it only from others.
+const cpuLimitSignal = syscall.Signal(0x18)
+
+type sandbox struct{}
+
+// IsSandboxProcess reports, whether the process is started as the sandbox of the checker.
+func IsSandboxProcess() bool {
+	return false
+}
+
+// RunSandboxProcess is never called on systems without the sandbox.
+func RunSandboxProcess() {}
+
+func newSandbox(appLogger *logger.Logger, options SandboxOptions) *sandbox {
+	if options.Enabled {
+		appLogger.Warn("Sandbox is supported on Linux only, the checker runs without it")
+	}
+	return nil
+}
+
+func (s *sandbox) prepareResult(string) error {
+	return nil
+}
+
+func (s *sandbox) command(ctx context.Context, name string, args []string, _ []string, _ []string) (*exec.Cmd, func(), error) {
+	return exec.CommandContext(ctx, name, args...), func() {}, nil
+}
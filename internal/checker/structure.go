@@ -0,0 +1,91 @@
+package checker
+
+import (
+	"CodeBorrowing/internal/structure"
+	"CodeBorrowing/pkg/logger"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+var ErrInvalidStructureWeight = errors.New("structure weight must be in [0, 1]")
+
+// StructureOptions describe the structural comparison.
+type StructureOptions struct {
+	MinTokens uint64  // Min size of matched subtrees in tokens.
+	Weight    float64 // Share of the structural similarity, when it is combined with another backend.
+}
+
+// structureChecker compares syntax trees of works inside the worker process.
+// Methods reordered, branches swapped and for loops turned into while loops still match.
+type structureChecker struct {
+	logger    *logger.Logger
+	results   *resultDirs
+	structure StructureOptions
+}
+
+func NewStructureChecker(appLogger *logger.Logger, results string, options Options, structure StructureOptions) Checker {
+	return &structureChecker{
+		logger:    appLogger,
+		results:   newResultDirs(appLogger, results, options.KeepFailed),
+		structure: structure,
+	}
+}
+
+func (c *structureChecker) Run(newWork string, oldWorks []string) (string, error) {
+	if newWork == "" || len(oldWorks) == 0 {
+		return "", ErrNoFiles
+	}
+	if err := checkInput(append([]string{newWork}, oldWorks...)); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	options := structure.Options{MinTokens: int(max(c.structure.MinTokens, 1))}
+
+	newTree, err := structure.LoadWork(newWork)
+	if err != nil {
+		return "", &RunError{Kind: ErrBadInput, Err: err}
+	}
+
+	comparisons := make([]structure.Comparison, 0)
+	for _, oldWork := range oldWorks {
+		oldTree, err := structure.LoadWork(oldWork)
+		if err != nil {
+			return "", &RunError{Kind: ErrBadInput, Err: err}
+		}
+		if comparison, ok := structure.Compare(newTree, oldTree, options); ok {
+			comparisons = append(comparisons, comparison)
+		}
+	}
+
+	resultPath, err := c.writeResults(comparisons)
+	if err != nil {
+		return "", err
+	}
+	c.logger.Debugf("Structural comparison finished in %s", time.Since(start).Round(time.Millisecond))
+
+	return resultPath, nil
+}
+
+func (c *structureChecker) writeResults(comparisons []structure.Comparison) (string, error) {
+	data, err := json.Marshal(comparisons)
+	if err != nil {
+		return "", err
+	}
+
+	resultPath, err := c.results.create()
+	if err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(resultPath, data, 0644); err != nil {
+		c.Release(resultPath, true)
+		return "", err
+	}
+	return resultPath, nil
+}
+
+func (c *structureChecker) Release(resultPath string, failed bool) {
+	c.results.release(resultPath, failed)
+}
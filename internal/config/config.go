@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Config struct {
@@ -14,6 +17,76 @@ type Config struct {
 	StorageSize    uint64
 	MainServerHost string
 	MainServerKey  string
+
+	LogMaxSize        uint64
+	LogRotateInterval time.Duration
+	LogCompress       bool
+	LogMaxFiles       uint64
+	LogMaxAge         time.Duration
+
+	LogConsoleLevel  string
+	LogFileLevel     string
+	LogPackageLevels string
+
+	AdminHost string
+	AdminKey  string
+
+	StorageReconcileInterval time.Duration
+	StorageHighWatermark     float64
+	StorageLowWatermark      float64
+	StorageTTL               time.Duration
+	StorageEvictionBatch     uint64
+	StorageActiveEventTTL    time.Duration
+
+	CheckerSkipExact        bool
+	CheckerCandidates       uint64
+	CheckerPrefilterMinWork uint64
+
+	CheckerTimeout     time.Duration
+	CheckerMaxHeap     uint64
+	CheckerMaxStack    uint64
+	CheckerProcessors  uint64
+	CheckerJavaOptions []string
+	CheckerKeepFailed  bool
+	CheckerResultCache bool
+
+	CheckerBackend       string
+	CheckerDaemonArgs    []string
+	CheckerDaemonMaxJobs uint64
+
+	CheckerCommand string
+	CheckerArgs    []string
+	CheckerOptions map[string]string
+
+	CheckerSandbox     bool
+	CheckerSandboxUser string
+	CheckerMaxCPU      uint64
+	CheckerMaxMemory   uint64
+	CheckerMaxFiles    uint64
+	CheckerTempSize    uint64
+
+	CheckerAstMinTokens uint64
+	CheckerAstWeight    float64
+
+	CrossLanguage              bool
+	CrossLanguageK             uint64
+	CrossLanguageMinSimilarity float64
+
+	FingerprintK      uint64
+	FingerprintWindow uint64
+
+	KnownCodeOverlap  float64
+	IgnoreCorpus      string
+	ReferenceArchives map[string]string
+
+	ExternalCorpora       map[string]string
+	ExternalMinSimilarity float64
+	ExternalMinLines      uint64
+
+	SubmissionLanguage    string
+	SubmissionInclude     []string
+	SubmissionExclude     []string
+	SubmissionMaxFileSize uint64
 }
 
 const (
@@ -23,6 +96,76 @@ const (
 	envCrossCheckLib  = "checkerPath"
 	envMainServerHost = "mainServerHost"
 	envMainServerKey  = "mainServerKey"
+
+	envLogMaxSize        = "logMaxSize"
+	envLogRotateInterval = "logRotateInterval"
+	envLogCompress       = "logCompress"
+	envLogMaxFiles       = "logMaxFiles"
+	envLogMaxAge         = "logMaxAge"
+
+	envLogConsoleLevel  = "logConsoleLevel"
+	envLogFileLevel     = "logFileLevel"
+	envLogPackageLevels = "logPackageLevels"
+
+	envAdminHost = "adminHost"
+	envAdminKey  = "adminKey"
+
+	envStorageReconcileInterval = "storageReconcileInterval"
+	envStorageHighWatermark     = "storageHighWatermark"
+	envStorageLowWatermark      = "storageLowWatermark"
+	envStorageTTL               = "storageTTL"
+	envStorageEvictionBatch     = "storageEvictionBatch"
+	envStorageActiveEventTTL    = "storageActiveEventTTL"
+
+	envCheckerSkipExact        = "checkerSkipExact"
+	envCheckerCandidates       = "checkerCandidates"
+	envCheckerPrefilterMinWork = "checkerPrefilterMinWorks"
+
+	envCheckerTimeout     = "checkerTimeout"
+	envCheckerMaxHeap     = "checkerMaxHeap"
+	envCheckerMaxStack    = "checkerMaxStack"
+	envCheckerProcessors  = "checkerProcessors"
+	envCheckerJavaOptions = "checkerJavaOptions"
+	envCheckerKeepFailed  = "checkerKeepFailed"
+	envCheckerResultCache = "checkerResultCache"
+
+	envCheckerBackend       = "checkerBackend"
+	envCheckerDaemonArgs    = "checkerDaemonArgs"
+	envCheckerDaemonMaxJobs = "checkerDaemonMaxJobs"
+
+	envCheckerCommand = "checkerCommand"
+	envCheckerArgs    = "checkerArgs"
+	envCheckerOptions = "checkerOptions"
+
+	envCheckerSandbox     = "checkerSandbox"
+	envCheckerSandboxUser = "checkerSandboxUser"
+	envCheckerMaxCPU      = "checkerMaxCpu"
+	envCheckerMaxMemory   = "checkerMaxMemory"
+	envCheckerMaxFiles    = "checkerMaxFiles"
+	envCheckerTempSize    = "checkerTempSize"
+
+	envCheckerAstMinTokens = "checkerAstMinTokens"
+	envCheckerAstWeight    = "checkerAstWeight"
+
+	envCrossLanguage              = "crossLanguage"
+	envCrossLanguageK             = "crossLanguageK"
+	envCrossLanguageMinSimilarity = "crossLanguageMinSimilarity"
+
+	envFingerprintK      = "fingerprintK"
+	envFingerprintWindow = "fingerprintWindow"
+
+	envKnownCodeOverlap  = "knownCodeOverlap"
+	envIgnoreCorpus      = "ignoreCorpus"
+	envReferenceArchives = "referenceArchives"
+
+	envExternalCorpora       = "externalCorpora"
+	envExternalMinSimilarity = "externalMinSimilarity"
+	envExternalMinLines      = "externalMinLines"
+
+	envSubmissionLanguage    = "submissionLanguage"
+	envSubmissionInclude     = "submissionInclude"
+	envSubmissionExclude     = "submissionExclude"
+	envSubmissionMaxFileSize = "submissionMaxFileSize"
 )
 
 var instance *Config
@@ -46,13 +189,45 @@ func GetConfig() (*Config, error) {
 		instance.MainServerHost = os.Getenv(envMainServerHost)
 		instance.MainServerKey = os.Getenv(envMainServerKey)
 		instance.StorageSize = cacheSize
+		instance.LogConsoleLevel = getEnvString(envLogConsoleLevel, "debug")
+		instance.LogFileLevel = getEnvString(envLogFileLevel, "info")
+		instance.LogPackageLevels = os.Getenv(envLogPackageLevels)
+		instance.AdminHost = os.Getenv(envAdminHost)
+		instance.AdminKey = os.Getenv(envAdminKey)
+		instance.IgnoreCorpus = getEnvString(envIgnoreCorpus, filepath.Join(instance.Storage, "ignore"))
+		instance.SubmissionLanguage = getEnvString(envSubmissionLanguage, "csharp")
+		instance.SubmissionInclude = getEnvList(envSubmissionInclude)
+		instance.SubmissionExclude = getEnvList(envSubmissionExclude)
+		instance.CheckerJavaOptions = getEnvList(envCheckerJavaOptions)
+		instance.CheckerSandboxUser = os.Getenv(envCheckerSandboxUser)
+		instance.CheckerBackend = getEnvString(envCheckerBackend, "process")
+		instance.CheckerCommand = os.Getenv(envCheckerCommand)
+		instance.CheckerArgs = getEnvList(envCheckerArgs)
+		instance.CheckerDaemonArgs = getEnvList(envCheckerDaemonArgs)
+		if len(instance.CheckerDaemonArgs) == 0 {
+			instance.CheckerDaemonArgs = []string{"--daemon"}
+		}
+
+		if configErr = readLogRotation(instance); configErr != nil {
+			return
+		}
+		if configErr = readStorageEviction(instance); configErr != nil {
+			return
+		}
+		if configErr = readChecker(instance); configErr != nil {
+			return
+		}
 
 		if instance.Logs == "" {
-			err = fmt.Errorf("environment variable: \"%s\" not found", envLogs)
+			configErr = fmt.Errorf("environment variable: \"%s\" not found", envLogs)
 		} else if instance.Storage == "" {
-			err = fmt.Errorf("environment variable: \"%s\" not found", envStorage)
-		} else if instance.CheckerPath == "" {
-			err = fmt.Errorf("environment variable: \"%s\" not found", envCrossCheckLib)
+			configErr = fmt.Errorf("environment variable: \"%s\" not found", envStorage)
+		} else if instance.AdminHost != "" && instance.AdminKey == "" {
+			configErr = fmt.Errorf("environment variable: \"%s\" is required with \"%s\"", envAdminKey, envAdminHost)
+		} else if instance.CheckerBackend == "daemon" && instance.CheckerSandbox {
+			configErr = fmt.Errorf("environment variable: \"%s\": the checker backend \"daemon\" can not run in the sandbox", envCheckerSandbox)
+		} else if name := checkerRequirement(instance); name != "" {
+			configErr = fmt.Errorf("environment variable: \"%s\" not found, it is required by the checker backend \"%s\"", name, instance.CheckerBackend)
 		} else {
 			isErr = false
 		}
@@ -63,3 +238,237 @@ func GetConfig() (*Config, error) {
 	}
 	return instance, nil
 }
+
+// checkerRequirement returns the missing setting, which the checker backend needs.
+// Unknown backends are reported, when the checker is created.
+func checkerRequirement(cfg *Config) string {
+	switch cfg.CheckerBackend {
+	case "process", "daemon":
+		if cfg.CheckerPath == "" {
+			return envCrossCheckLib
+		}
+	case "external":
+		if cfg.CheckerCommand == "" {
+			return envCheckerCommand
+		}
+	}
+	return ""
+}
+
+// readLogRotation reads optional settings of log files rotation.
+func readLogRotation(cfg *Config) (err error) {
+	if cfg.LogMaxSize, err = getEnvUint(envLogMaxSize, 10); err != nil {
+		return err
+	}
+	if cfg.LogRotateInterval, err = getEnvDuration(envLogRotateInterval, 24*time.Hour); err != nil {
+		return err
+	}
+	if cfg.LogCompress, err = getEnvBool(envLogCompress, true); err != nil {
+		return err
+	}
+	if cfg.LogMaxFiles, err = getEnvUint(envLogMaxFiles, 30); err != nil {
+		return err
+	}
+	if cfg.LogMaxAge, err = getEnvDuration(envLogMaxAge, 30*24*time.Hour); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readStorageEviction reads optional settings of the works cache.
+func readStorageEviction(cfg *Config) (err error) {
+	if cfg.StorageReconcileInterval, err = getEnvDuration(envStorageReconcileInterval, 6*time.Hour); err != nil {
+		return err
+	}
+	if cfg.StorageHighWatermark, err = getEnvFloat(envStorageHighWatermark, 0.9); err != nil {
+		return err
+	}
+	if cfg.StorageLowWatermark, err = getEnvFloat(envStorageLowWatermark, 0.7); err != nil {
+		return err
+	}
+	if cfg.StorageTTL, err = getEnvDuration(envStorageTTL, 0); err != nil {
+		return err
+	}
+	if cfg.StorageEvictionBatch, err = getEnvUint(envStorageEvictionBatch, 50); err != nil {
+		return err
+	}
+	if cfg.StorageActiveEventTTL, err = getEnvDuration(envStorageActiveEventTTL, time.Hour); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readChecker reads optional settings of checking works.
+func readChecker(cfg *Config) (err error) {
+	if cfg.CheckerSkipExact, err = getEnvBool(envCheckerSkipExact, false); err != nil {
+		return err
+	}
+	if cfg.CheckerCandidates, err = getEnvUint(envCheckerCandidates, 50); err != nil {
+		return err
+	}
+	if cfg.CheckerPrefilterMinWork, err = getEnvUint(envCheckerPrefilterMinWork, 100); err != nil {
+		return err
+	}
+	if cfg.CheckerTimeout, err = getEnvDuration(envCheckerTimeout, 10*time.Minute); err != nil {
+		return err
+	}
+	if cfg.CheckerMaxHeap, err = getEnvUint(envCheckerMaxHeap, 1024); err != nil {
+		return err
+	}
+	if cfg.CheckerMaxStack, err = getEnvUint(envCheckerMaxStack, 0); err != nil {
+		return err
+	}
+	if cfg.CheckerProcessors, err = getEnvUint(envCheckerProcessors, 0); err != nil {
+		return err
+	}
+	if cfg.CheckerOptions, err = getEnvMap(envCheckerOptions); err != nil {
+		return err
+	}
+	if cfg.CheckerDaemonMaxJobs, err = getEnvUint(envCheckerDaemonMaxJobs, 100); err != nil {
+		return err
+	}
+	if cfg.CheckerKeepFailed, err = getEnvBool(envCheckerKeepFailed, false); err != nil {
+		return err
+	}
+	if cfg.CheckerResultCache, err = getEnvBool(envCheckerResultCache, true); err != nil {
+		return err
+	}
+	if cfg.CheckerSandbox, err = getEnvBool(envCheckerSandbox, false); err != nil {
+		return err
+	}
+	if cfg.CheckerMaxCPU, err = getEnvUint(envCheckerMaxCPU, 0); err != nil {
+		return err
+	}
+	if cfg.CheckerMaxMemory, err = getEnvUint(envCheckerMaxMemory, 0); err != nil {
+		return err
+	}
+	if cfg.CheckerMaxFiles, err = getEnvUint(envCheckerMaxFiles, 1024); err != nil {
+		return err
+	}
+	if cfg.CheckerTempSize, err = getEnvUint(envCheckerTempSize, 256); err != nil {
+		return err
+	}
+	if cfg.CheckerAstMinTokens, err = getEnvUint(envCheckerAstMinTokens, 20); err != nil {
+		return err
+	}
+	if cfg.CheckerAstWeight, err = getEnvFloat(envCheckerAstWeight, 0); err != nil {
+		return err
+	}
+	if cfg.CrossLanguage, err = getEnvBool(envCrossLanguage, false); err != nil {
+		return err
+	}
+	if cfg.CrossLanguageK, err = getEnvUint(envCrossLanguageK, 15); err != nil {
+		return err
+	}
+	if cfg.CrossLanguageMinSimilarity, err = getEnvFloat(envCrossLanguageMinSimilarity, 20); err != nil {
+		return err
+	}
+	if cfg.FingerprintK, err = getEnvUint(envFingerprintK, 20); err != nil {
+		return err
+	}
+	if cfg.FingerprintWindow, err = getEnvUint(envFingerprintWindow, 20); err != nil {
+		return err
+	}
+	if cfg.KnownCodeOverlap, err = getEnvFloat(envKnownCodeOverlap, 0.5); err != nil {
+		return err
+	}
+	if cfg.ReferenceArchives, err = getEnvMap(envReferenceArchives); err != nil {
+		return err
+	}
+	if cfg.ExternalCorpora, err = getEnvMap(envExternalCorpora); err != nil {
+		return err
+	}
+	if cfg.ExternalMinSimilarity, err = getEnvFloat(envExternalMinSimilarity, 20); err != nil {
+		return err
+	}
+	if cfg.ExternalMinLines, err = getEnvUint(envExternalMinLines, 5); err != nil {
+		return err
+	}
+	if cfg.SubmissionMaxFileSize, err = getEnvUint(envSubmissionMaxFileSize, 1024); err != nil {
+		return err
+	}
+	return nil
+}
+
+func getEnvString(name string, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// getEnvList reads the comma separated list.
+func getEnvList(name string) []string {
+	var res []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// getEnvMap reads the comma separated list of "key=value" pairs.
+func getEnvMap(name string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, item := range getEnvList(name) {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("environment variable: \"%s\": \"%s\" is not a key=value pair", name, item)
+		}
+		res[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return res, nil
+}
+
+func getEnvUint(name string, def uint64) (uint64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	res, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable: \"%s\": %w", name, err)
+	}
+	return res, nil
+}
+
+func getEnvDuration(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	res, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable: \"%s\": %w", name, err)
+	}
+	return res, nil
+}
+
+func getEnvFloat(name string, def float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	res, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable: \"%s\": %w", name, err)
+	}
+	return res, nil
+}
+
+func getEnvBool(name string, def bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	res, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("environment variable: \"%s\": %w", name, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckerRequirement(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"process with jar", Config{CheckerBackend: "process", CheckerPath: "a.jar"}, ""},
+		{"process with command only", Config{CheckerBackend: "process", CheckerCommand: "check"}, envCrossCheckLib},
+		{"daemon without jar", Config{CheckerBackend: "daemon"}, envCrossCheckLib},
+		{"external with command", Config{CheckerBackend: "external", CheckerCommand: "check"}, ""},
+		{"external with jar only", Config{CheckerBackend: "external", CheckerPath: "a.jar"}, envCheckerCommand},
+		{"ast", Config{CheckerBackend: "ast"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkerRequirement(&tt.cfg); got != tt.want {
+				t.Errorf("checkerRequirement() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvMap(t *testing.T) {
+	const name = "testEnvMap"
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"a=1", map[string]string{"a": "1"}, false},
+		{"a = 1, b=x=y", map[string]string{"a": "1", "b": "x=y"}, false},
+		{"a=1,b", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv(name, tt.value)
+			got, err := getEnvMap(name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getEnvMap() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getEnvMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidOptions = errors.New("fingerprint k-gram size and window must be positive")
+
+// Options of the winnowing algorithm. Any match of at least K+Window-1 symbols
+// is guaranteed to share a fingerprint, matches shorter than K are ignored.
+type Options struct {
+	K      int // Size of k-grams.
+	Window int // Count of k-gram hashes in a window.
+}
+
+func (o Options) Validate() error {
+	if o.K <= 0 || o.Window <= 0 {
+		return ErrInvalidOptions
+	}
+	return nil
+}
+
+// Key identifies fingerprints made with the options.
+// Fingerprints with different keys are not comparable.
+func (o Options) Key() string {
+	return fmt.Sprintf("k%d-w%d", o.K, o.Window)
+}
+
+const hashBase = 1099511628211 // FNV prime, any odd number works.
+
+// mix spreads bits of the rolling hash, so minimums of windows are not biased
+// to k-grams with small symbols.
+func mix(hash uint64) uint64 {
+	hash ^= hash >> 30
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 27
+	hash *= 0x94d049bb133111eb
+	hash ^= hash >> 31
+	return hash
+}
+
+// kgramHashes returns hashes of all k-grams of the content.
+func kgramHashes(content []byte, k int) []uint64 {
+	if len(content) < k {
+		return nil
+	}
+
+	var power uint64 = 1
+	for i := 0; i < k-1; i++ {
+		power *= hashBase
+	}
+
+	var hash uint64 = 0
+	for i := 0; i < k; i++ {
+		hash = hash*hashBase + uint64(content[i])
+	}
+
+	hashes := make([]uint64, 0, len(content)-k+1)
+	hashes = append(hashes, mix(hash))
+	for i := k; i < len(content); i++ {
+		hash = (hash-uint64(content[i-k])*power)*hashBase + uint64(content[i])
+		hashes = append(hashes, mix(hash))
+	}
+
+	return hashes
+}
+
+// Fingerprint is a selected k-gram hash and the offset of the k-gram in the content.
+type Fingerprint struct {
+	Hash uint64
+	Pos  int
+}
+
+// WinnowPositions selects fingerprints of the content: the minimal k-gram hash
+// of every window (the rightmost one on ties). The same k-gram selected
+// by consecutive windows is returned once.
+func WinnowPositions(content []byte, opts Options) []Fingerprint {
+	hashes := kgramHashes(content, opts.K)
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	window := min(opts.Window, len(hashes))
+	result := make([]Fingerprint, 0, 2*len(hashes)/(window+1)+1)
+	selected := -1
+
+	for start := 0; start+window <= len(hashes); start++ {
+		end := start + window - 1
+
+		if selected < start {
+			// The previous minimum left the window, search the whole window.
+			selected = end
+			for i := end - 1; i >= start; i-- {
+				if hashes[i] < hashes[selected] {
+					selected = i
+				}
+			}
+		} else if hashes[end] <= hashes[selected] {
+			selected = end
+		} else {
+			continue
+		}
+
+		result = append(result, Fingerprint{Hash: hashes[selected], Pos: selected})
+	}
+
+	return result
+}
+
+// Winnow returns unique fingerprint hashes of the content.
+func Winnow(content []byte, opts Options) []uint64 {
+	fingerprints := WinnowPositions(content, opts)
+	seen := make(map[uint64]struct{}, len(fingerprints))
+	result := make([]uint64, 0, len(fingerprints))
+
+	for _, fp := range fingerprints {
+		if _, ok := seen[fp.Hash]; !ok {
+			seen[fp.Hash] = struct{}{}
+			result = append(result, fp.Hash)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,182 @@
+package ignore
+
+import (
+	"CodeBorrowing/internal/fingerprint"
+	"CodeBorrowing/internal/normalize"
+	"CodeBorrowing/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	manifestFile = "manifest.json"
+	snippetsDir  = "snippets"
+)
+
+var ErrEmptySnippet = errors.New("snippet has no code")
+
+// Snippet is a piece of common code, which is not reported as borrowing.
+type Snippet struct {
+	ID     uint64    `json:"id"`
+	Name   string    `json:"name"`
+	Source string    `json:"source,omitempty"` // Where the snippet came from, e.g. a false positive report.
+	Added  time.Time `json:"added"`
+}
+
+type manifest struct {
+	Version  uint64    `json:"version"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// Corpus is a local versioned list of known common snippets.
+// The version grows with every change.
+type Corpus interface {
+	Version() uint64
+	List() []Snippet
+	Fingerprints() map[uint64]struct{}
+	Add(name string, source string, content []byte) (Snippet, error)
+}
+
+type corpus struct {
+	mu           sync.RWMutex
+	root         string
+	options      fingerprint.Options
+	language     string // Language of snippets, whose names have no known extension.
+	manifest     manifest
+	fingerprints map[uint64]struct{}
+}
+
+// NewCorpus loads the corpus from the directory. An empty corpus is created, if there is none.
+// Snippets are tokenized by the extension of their names or as the language.
+func NewCorpus(root string, options fingerprint.Options, language string) (Corpus, error) {
+	if _, err := utils.CreateDirectory(filepath.Join(root, snippetsDir)); err != nil {
+		return nil, err
+	}
+
+	c := &corpus{
+		root:         root,
+		options:      options,
+		language:     language,
+		manifest:     manifest{Snippets: make([]Snippet, 0)},
+		fingerprints: make(map[uint64]struct{}),
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, manifestFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &c.manifest); err != nil {
+			return nil, fmt.Errorf("ignore corpus manifest: %w", err)
+		}
+	}
+
+	for _, snippet := range c.manifest.Snippets {
+		content, err := os.ReadFile(c.snippetPath(snippet.ID))
+		if err != nil {
+			return nil, err
+		}
+		c.addFingerprints(snippet.Name, content)
+	}
+
+	return c, nil
+}
+
+func (c *corpus) snippetPath(id uint64) string {
+	return filepath.Join(c.root, snippetsDir, fmt.Sprintf("%d.txt", id))
+}
+
+func (c *corpus) addFingerprints(name string, content []byte) int {
+	fingerprints := fingerprint.Winnow(normalize.File(name, content, c.language), c.options)
+	for _, fp := range fingerprints {
+		c.fingerprints[fp] = struct{}{}
+	}
+	return len(fingerprints)
+}
+
+// saveManifest writes the manifest through a temporary file, so it is never half-written.
+func (c *corpus) saveManifest() error {
+	data, err := json.MarshalIndent(c.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(c.root, manifestFile+".tmp")
+	if err = os.WriteFile(tmp, data, os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(c.root, manifestFile))
+}
+
+func (c *corpus) Version() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.manifest.Version
+}
+
+func (c *corpus) List() []Snippet {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]Snippet{}, c.manifest.Snippets...)
+}
+
+// Fingerprints returns fingerprints of all snippets. The result must not be changed.
+func (c *corpus) Fingerprints() map[uint64]struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.fingerprints
+}
+
+func (c *corpus) Add(name string, source string, content []byte) (Snippet, error) {
+	if len(fingerprint.Winnow(normalize.File(name, content, c.language), c.options)) == 0 {
+		return Snippet{}, ErrEmptySnippet
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var id uint64 = 1
+	for _, snippet := range c.manifest.Snippets {
+		id = max(id, snippet.ID+1)
+	}
+
+	snippet := Snippet{
+		ID:     id,
+		Name:   strings.TrimSpace(name),
+		Source: source,
+		Added:  time.Now(),
+	}
+
+	if err := os.WriteFile(c.snippetPath(id), content, os.ModePerm); err != nil {
+		return snippet, err
+	}
+
+	c.manifest.Snippets = append(c.manifest.Snippets, snippet)
+	c.manifest.Version++
+	if err := c.saveManifest(); err != nil {
+		c.manifest.Snippets = c.manifest.Snippets[:len(c.manifest.Snippets)-1]
+		c.manifest.Version--
+		_ = os.Remove(c.snippetPath(id))
+		return snippet, err
+	}
+
+	// Readers keep the old set, so the new one is built as a copy.
+	fingerprints := make(map[uint64]struct{}, len(c.fingerprints))
+	for fp := range c.fingerprints {
+		fingerprints[fp] = struct{}{}
+	}
+	c.fingerprints = fingerprints
+	c.addFingerprints(snippet.Name, content)
+
+	return snippet, nil
+}
@@ -0,0 +1,104 @@
+package normalize
+
+import "CodeBorrowing/internal/tokenize"
+
+// Version of the normalization. Hashes and fingerprints of normalized code
+// stored by other versions must be recalculated.
+const Version = 4
+
+// Markers of changes of indentation in the result of indented languages.
+// They are control characters, which are never in tokens.
+const (
+	indentMarker = '\x01'
+	dedentMarker = '\x02'
+)
+
+// Normalization removes only comments and whitespace, so all tokens are kept as is.
+var fileOptions = tokenize.Options{KeepIdentifiers: true, KeepLiterals: true, KeepImports: true, KeepDirectives: true}
+
+// File removes comments and formatting from the source file: tokens are separated by one space.
+// Indentation of indented languages is replaced by markers of blocks.
+// The tokenizer is selected by the extension of the file or by the default language,
+// files of unknown languages are normalized as C-like code.
+func File(name string, content []byte, language string) []byte {
+	result, _ := file(name, content, language, false)
+	return result
+}
+
+// FileLines works as File and also returns the line of the content (from 1)
+// for every byte of the result.
+func FileLines(name string, content []byte, language string) ([]byte, []uint64) {
+	return file(name, content, language, true)
+}
+
+func file(name string, content []byte, language string, withLines bool) ([]byte, []uint64) {
+	lang, ok := tokenize.Select(name, language)
+	if !ok {
+		return code(content, withLines)
+	}
+
+	result := make([]byte, 0, len(content))
+	var lines []uint64
+	if withLines {
+		lines = make([]uint64, 0, len(content))
+	}
+
+	appendByte := func(c byte, line uint64) {
+		result = append(result, c)
+		if withLines {
+			lines = append(lines, line)
+		}
+	}
+
+	indents := []int{1} // Columns of open blocks.
+	depth := 0          // Open brackets, inside them lines are continued.
+	lastLine := 0       // The last line of the previous token.
+
+	for i, token := range lang.Lexer(content, fileOptions) {
+		line := uint64(token.Line)
+		if i > 0 {
+			appendByte(' ', line)
+		}
+
+		// Tokens inside multiline strings are not at the start of a line.
+		if lang.Indented && depth == 0 && token.Line > lastLine {
+			for token.Column < indents[len(indents)-1] {
+				indents = indents[:len(indents)-1]
+				appendByte(dedentMarker, line)
+			}
+			if token.Column > indents[len(indents)-1] {
+				indents = append(indents, token.Column)
+				appendByte(indentMarker, line)
+			}
+		}
+		if token.Kind == tokenize.Operator {
+			switch token.Raw {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth = max(depth-1, 0)
+			}
+		}
+
+		// Directives are not split into tokens, their whitespace is collapsed here.
+		gap := false
+		for j := 0; j < len(token.Raw); j++ {
+			c := token.Raw[j]
+			if c == '\n' {
+				line++
+			}
+			if token.Kind == tokenize.Directive && (c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\\') {
+				gap = true
+				continue
+			}
+			if gap {
+				appendByte(' ', line)
+				gap = false
+			}
+			appendByte(c, line)
+		}
+		lastLine = max(lastLine, int(line))
+	}
+
+	return result, lines
+}
@@ -0,0 +1,65 @@
+package normalize
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFileKeepsTokenBoundaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		a, b     string
+		language string
+	}{
+		{"python indentation", "a.py", "if a:\n    x()\ny()\n", "if a:\n    x()\n    y()\n", "python"},
+		{"python nested blocks", "a.py", "for i in a:\n  if i:\n    x()\n  y()\n", "for i in a:\n  if i:\n    x()\ny()\n", "python"},
+		{"separate words", "a.cs", "return x;", "returnx;", "csharp"},
+		{"directive words", "a.c", "#define A B\n", "#define AB\n", "cpp"},
+		{"operators", "a.go", "a := - -b", "a := --b", "go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := File(tt.file, []byte(tt.a), tt.language)
+			b := File(tt.file, []byte(tt.b), tt.language)
+			if bytes.Equal(a, b) {
+				t.Errorf("different programs normalize to the same %q", a)
+			}
+		})
+	}
+}
+
+func TestFileIgnoresFormatting(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		a, b     string
+		language string
+	}{
+		{"csharp comments", "a.cs", "int x = 1; // one\n/* two */ return x;", "int x=1;\nreturn  x;", "csharp"},
+		{"python blank lines", "a.py", "def f():\n\n    # c\n    return 1\n", "def f():\n  return 1\n", "python"},
+		{"python continued lines", "a.py", "x = f(1,\n      2)\ny = 3\n", "x = f(1, 2)\ny = 3\n", "python"},
+		{"directive spaces", "a.c", "#include  <a.h>\n", "#include <a.h>\n", "cpp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := File(tt.file, []byte(tt.a), tt.language)
+			b := File(tt.file, []byte(tt.b), tt.language)
+			if !bytes.Equal(a, b) {
+				t.Errorf("got %q and %q, want the same result", a, b)
+			}
+		})
+	}
+}
+
+func TestFileLines(t *testing.T) {
+	code, lines := FileLines("a.py", []byte("if a:\n    x()\n"), "python")
+	if len(code) != len(lines) {
+		t.Fatalf("got %d lines for %d bytes", len(lines), len(code))
+	}
+	if lines[0] != 1 || lines[len(lines)-1] != 2 {
+		t.Errorf("got lines %v of %q", lines, code)
+	}
+}
@@ -0,0 +1,122 @@
+package normalize
+
+import "bytes"
+
+// Code removes comments and whitespace from the source code of C-like languages.
+// Whitespace and comments between word characters are replaced by one space, so words are not glued.
+// String and char literals are kept as is, so only formatting and comments
+// do not affect the result.
+func Code(content []byte) []byte {
+	result, _ := code(content, false)
+	return result
+}
+
+// CodeLines works as Code and also returns the line of the content (from 1)
+// for every byte of the result.
+func CodeLines(content []byte) ([]byte, []uint64) {
+	return code(content, true)
+}
+
+func code(content []byte, withLines bool) ([]byte, []uint64) {
+	result := make([]byte, 0, len(content))
+	var lines []uint64
+	if withLines {
+		lines = make([]uint64, 0, len(content))
+	}
+
+	// Lines are counted lazily up to the appended byte.
+	var line uint64 = 1
+	lineAt := 0
+	lineOf := func(i int) uint64 {
+		for ; lineAt < i; lineAt++ {
+			if content[lineAt] == '\n' {
+				line++
+			}
+		}
+		return line
+	}
+
+	gap := false // Whitespace or comments were skipped after the last appended byte.
+	appendRange := func(from, to int) {
+		if gap && len(result) > 0 && isWordByte(result[len(result)-1]) && isWordByte(content[from]) {
+			result = append(result, ' ')
+			if withLines {
+				lines = append(lines, lineOf(from))
+			}
+		}
+		gap = false
+
+		result = append(result, content[from:to]...)
+		if !withLines {
+			return
+		}
+		for i := from; i < to; i++ {
+			lines = append(lines, lineOf(i))
+		}
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		case c == '/' && i+1 < len(content) && content[i+1] == '/':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			gap = true
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			end := bytes.Index(content[i+2:], []byte("*/"))
+			if end == -1 {
+				return result, lines
+			}
+			i += end + 3
+			gap = true
+		case c == '@' && i+1 < len(content) && content[i+1] == '"':
+			// Verbatim string: quotes are escaped by doubling.
+			start := i
+			for i += 2; i < len(content); i++ {
+				if content[i] == '"' {
+					if i+1 < len(content) && content[i+1] == '"' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			appendRange(start, min(i+1, len(content)))
+		case c == '"' || c == '\'':
+			start := i
+			for i++; i < len(content) && content[i] != c && content[i] != '\n'; i++ {
+				if content[i] == '\\' {
+					i++
+				}
+			}
+			appendRange(start, min(i+1, len(content)))
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			gap = true
+		default:
+			appendRange(i, i+1)
+		}
+	}
+
+	return result, lines
+}
+
+// isWordByte reports, whether the byte is a part of identifiers, keywords or numbers.
+// Bytes of multibyte UTF-8 characters are parts of identifiers.
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+// CountLines returns the count of lines in the content.
+func CountLines(content []byte) uint64 {
+	if len(content) == 0 {
+		return 0
+	}
+
+	lines := uint64(bytes.Count(content, []byte("\n")))
+	if content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
@@ -0,0 +1,61 @@
+package normalize
+
+import "testing"
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"separate words", "return x;", "return x;"},
+		{"glued words", "returnx;", "returnx;"},
+		{"whitespace runs", "int  \t\n x = 1 ;", "int x=1;"},
+		{"line comment", "a // c\nb", "a b"},
+		{"block comment between words", "a/* c */b", "a b"},
+		{"block comment between operators", "a +/* c */ b", "a+b"},
+		{"strings", `s = "a  b" + 'c';`, `s="a  b"+'c';`},
+		{"verbatim string", `s = @"a ""b"" c";`, `s=@"a ""b"" c";`},
+		{"unicode words", "var имя = 1;", "var имя=1;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Code([]byte(tt.in))); got != tt.want {
+				t.Errorf("Code(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeLines(t *testing.T) {
+	code, lines := CodeLines([]byte("a\n// c\nb\n"))
+	if string(code) != "a b" {
+		t.Fatalf("got %q", code)
+	}
+	want := []uint64{1, 3, 3}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("got lines %v, want %v", lines, want)
+		}
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0},
+		{"a", 1},
+		{"a\n", 1},
+		{"a\nb", 2},
+		{"\n\n", 2},
+	}
+
+	for _, tt := range tests {
+		if got := CountLines([]byte(tt.in)); got != tt.want {
+			t.Errorf("CountLines(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package notebook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Extension of Jupyter notebooks.
+const Extension = ".ipynb"
+
+// markerPrefix begins comment lines, which mark the start of cells in the source.
+const markerPrefix = "# %% cell "
+
+var ErrNotNotebook = errors.New("not a Jupyter notebook")
+
+// Extensions of sources by languages of kernels.
+var sourceExtensions = map[string]string{
+	"python": ".py",
+	"r":      ".r",
+	"julia":  ".jl",
+}
+
+const defaultLanguage = "python"
+
+// Notebook is the source made of code cells of a notebook.
+type Notebook struct {
+	Language string // Language of the kernel.
+	Source   []byte // Code cells, each after its marker line.
+}
+
+// text is a string, which notebooks store as a string or as an array of lines.
+type text string
+
+func (t *text) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = text(strings.Join(lines, ""))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = text(s)
+	return nil
+}
+
+type cell struct {
+	CellType string `json:"cell_type"`
+	Source   text   `json:"source"` // nbformat 4.
+	Input    text   `json:"input"`  // nbformat 3.
+}
+
+type document struct {
+	Cells      []cell `json:"cells"`
+	Worksheets []struct {
+		Cells []cell `json:"cells"`
+	} `json:"worksheets"`
+	Metadata struct {
+		Language   string `json:"language"`
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+// IsNotebook reports, whether the file is a notebook by its name.
+func IsNotebook(name string) bool {
+	return strings.EqualFold(path.Ext(name), Extension)
+}
+
+// SourceName returns the name of the source of the notebook, which tokenizers recognize.
+func SourceName(name string, language string) string {
+	ext, ok := sourceExtensions[language]
+	if !ok {
+		ext = ".txt"
+	}
+	return name + ext
+}
+
+// NotebookName returns the name of the notebook by the name of its source.
+func NotebookName(source string) (string, bool) {
+	name := strings.TrimSuffix(source, path.Ext(source))
+	if name == source || !IsNotebook(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// Extract pulls code cells out of the notebook, outputs and metadata are dropped.
+// Cells are numbered from 1 among cells of all types, so numbers match the notebook.
+// IPython magics and shell commands are commented out, so lines of cells are kept.
+func Extract(content []byte) (Notebook, error) {
+	var doc document
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return Notebook{}, fmt.Errorf("%w: %v", ErrNotNotebook, err)
+	}
+
+	cells := doc.Cells
+	for _, worksheet := range doc.Worksheets {
+		cells = append(cells, worksheet.Cells...)
+	}
+	if cells == nil {
+		return Notebook{}, ErrNotNotebook
+	}
+
+	result := Notebook{Language: defaultLanguage}
+	for _, language := range []string{doc.Metadata.Kernelspec.Language, doc.Metadata.LanguageInfo.Name, doc.Metadata.Language} {
+		if language != "" {
+			result.Language = strings.ToLower(language)
+			break
+		}
+	}
+
+	var source bytes.Buffer
+	for i, c := range cells {
+		if c.CellType != "code" {
+			continue
+		}
+		code := string(c.Source)
+		if code == "" {
+			code = string(c.Input)
+		}
+
+		source.WriteString(markerPrefix + strconv.Itoa(i+1) + "\n")
+		for _, line := range strings.Split(strings.TrimRight(code, "\n"), "\n") {
+			if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "!") {
+				line = "#" + line
+			}
+			source.WriteString(line + "\n")
+		}
+	}
+
+	result.Source = source.Bytes()
+	return result, nil
+}
+
+// Position is a line of a cell, both from 1.
+type Position struct {
+	Cell uint64
+	Line uint64
+}
+
+// Locate returns the position in the notebook of the line (from 1) of its source.
+// Marker lines belong to the next cell. Lines outside of the source are not found.
+func Locate(source []byte, line uint64) (Position, bool) {
+	lines := bytes.Split(source, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if line == 0 || line > uint64(len(lines)) {
+		return Position{}, false
+	}
+
+	var current Position
+	found := false
+
+	for i, content := range lines[:line] {
+		if rest, ok := bytes.CutPrefix(content, []byte(markerPrefix)); ok {
+			if cellNumber, err := strconv.ParseUint(string(rest), 10, 64); err == nil {
+				current = Position{Cell: cellNumber, Line: uint64(i + 1)}
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return Position{}, false
+	}
+	return Position{Cell: current.Cell, Line: max(line-current.Line, 1)}, true
+}
@@ -0,0 +1,78 @@
+package notebook
+
+import "testing"
+
+const testNotebook = `{
+	"metadata": {"kernelspec": {"language": "python"}},
+	"cells": [
+		{"cell_type": "code", "source": ["import os\n", "x = 1\n"]},
+		{"cell_type": "markdown", "source": "# Title"},
+		{"cell_type": "code", "source": "%matplotlib inline\n!ls\ny = x"}
+	]
+}`
+
+func TestExtract(t *testing.T) {
+	nb, err := Extract([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# %% cell 1\nimport os\nx = 1\n# %% cell 3\n#%matplotlib inline\n#!ls\ny = x\n"
+	if nb.Language != "python" || string(nb.Source) != want {
+		t.Errorf("Extract() = %q of %q, want %q of python", nb.Source, nb.Language, want)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	nb, err := Extract([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line uint64
+		want Position
+	}{
+		{1, Position{Cell: 1, Line: 1}}, // The marker belongs to the next cell.
+		{2, Position{Cell: 1, Line: 1}},
+		{3, Position{Cell: 1, Line: 2}},
+		{4, Position{Cell: 3, Line: 1}},
+		{7, Position{Cell: 3, Line: 3}},
+	}
+
+	for _, tt := range tests {
+		got, ok := Locate(nb.Source, tt.line)
+		if !ok || got != tt.want {
+			t.Errorf("Locate(%d) = %+v, %v, want %+v", tt.line, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := Locate([]byte("x = 1\n"), 1); ok {
+		t.Error("Locate() found a cell in the source without markers")
+	}
+	for _, line := range []uint64{0, 8, 100} {
+		if got, ok := Locate(nb.Source, line); ok {
+			t.Errorf("Locate(%d) = %+v, want the line outside of the source", line, got)
+		}
+	}
+}
+
+func TestNotebookName(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		ok     bool
+	}{
+		{"dir/a.ipynb.py", "dir/a.ipynb", true},
+		{"a.IPYNB.r", "a.IPYNB", true},
+		{"a.py", "", false},
+		{"a.ipynb", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NotebookName(tt.source)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NotebookName(%q) = %q, %v, want %q, %v", tt.source, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package reference
+
+import (
+	"CodeBorrowing/internal/charset"
+	"CodeBorrowing/internal/fingerprint"
+	"CodeBorrowing/internal/normalize"
+	"CodeBorrowing/internal/tokenize"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// maxPostings limits entries of one fingerprint. Fingerprints of more entries are common code,
+// which does not show where the work came from.
+const maxPostings = 64
+
+// Entry is a file of known public sources: a textbook example, a popular solution or an answer key.
+type Entry struct {
+	Name  string // Path of the file inside the directory of the corpus.
+	Lines uint64
+}
+
+// posting is a fingerprint of the entry with its lines.
+type posting struct {
+	entry int
+	start uint64
+	end   uint64
+}
+
+// Span is a pair of matched spans of the file and the entry, lines start from 1.
+type Span struct {
+	Start1 uint64
+	End1   uint64
+	Start2 uint64
+	End2   uint64
+}
+
+// Match is the part of the file found in the entry.
+type Match struct {
+	Entry        Entry
+	Spans        []Span
+	Covered      map[uint64]struct{} // Lines of the file found in the entry.
+	EntryCovered map[uint64]struct{} // Lines of the entry found in the file.
+}
+
+// Corpus is a named local directory of known public sources indexed by fingerprints.
+// It is indexed once when it is loaded and is not changed later.
+type Corpus struct {
+	name     string
+	options  fingerprint.Options
+	language string
+	entries  []Entry
+	index    map[uint64][]posting
+}
+
+// Load indexes files of the directory, which have extensions of known languages.
+// Files are normalized and fingerprinted as files of works.
+func Load(name string, root string, options fingerprint.Options, language string) (*Corpus, error) {
+	c := &Corpus{
+		name:     name,
+		options:  options,
+		language: language,
+		entries:  make([]Entry, 0),
+		index:    make(map[uint64][]posting),
+	}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if _, ok := tokenize.ForFile(path); !ok {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(root, path)
+		c.add(filepath.ToSlash(relPath), charset.Text(content))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for hash, postings := range c.index {
+		if len(postings) > maxPostings {
+			delete(c.index, hash)
+		}
+	}
+	return c, nil
+}
+
+func (c *Corpus) add(name string, content []byte) {
+	id := len(c.entries)
+	c.entries = append(c.entries, Entry{Name: name, Lines: normalize.CountLines(content)})
+
+	code, lines := normalize.FileLines(name, content, c.language)
+	seen := make(map[uint64]struct{})
+	for _, fp := range fingerprint.WinnowPositions(code, c.options) {
+		if _, ok := seen[fp.Hash]; ok {
+			continue
+		}
+		seen[fp.Hash] = struct{}{}
+		c.index[fp.Hash] = append(c.index[fp.Hash], posting{entry: id, start: lines[fp.Pos], end: lines[fp.Pos+c.options.K-1]})
+	}
+}
+
+func (c *Corpus) Name() string {
+	return c.name
+}
+
+// Len returns the count of entries.
+func (c *Corpus) Len() int {
+	return len(c.entries)
+}
+
+// hit is a fingerprint of the file found in the entry.
+type hit struct {
+	posting
+	start uint64 // Lines of the file.
+	end   uint64
+}
+
+// Find returns entries sharing fingerprints with the file ordered by names.
+// Fingerprints overlapping or adjacent in both the file and the entry make one span.
+func (c *Corpus) Find(name string, content []byte) []Match {
+	code, lines := normalize.FileLines(name, content, c.language)
+
+	byEntry := make(map[int][]hit)
+	for _, fp := range fingerprint.WinnowPositions(code, c.options) {
+		for _, p := range c.index[fp.Hash] {
+			byEntry[p.entry] = append(byEntry[p.entry], hit{posting: p, start: lines[fp.Pos], end: lines[fp.Pos+c.options.K-1]})
+		}
+	}
+
+	result := make([]Match, 0, len(byEntry))
+	for entry, hits := range byEntry {
+		sort.Slice(hits, func(i, j int) bool {
+			if hits[i].start != hits[j].start {
+				return hits[i].start < hits[j].start
+			}
+			return hits[i].posting.start < hits[j].posting.start
+		})
+
+		match := Match{
+			Entry:        c.entries[entry],
+			Covered:      make(map[uint64]struct{}),
+			EntryCovered: make(map[uint64]struct{}),
+		}
+		for _, h := range hits {
+			for line := h.start; line <= h.end; line++ {
+				match.Covered[line] = struct{}{}
+			}
+			for line := h.posting.start; line <= h.posting.end; line++ {
+				match.EntryCovered[line] = struct{}{}
+			}
+
+			if span := lastSpan(match.Spans); span != nil && h.start <= span.End1+1 &&
+				h.posting.start <= span.End2+1 && span.Start2 <= h.posting.end+1 {
+				span.End1 = max(span.End1, h.end)
+				span.Start2 = min(span.Start2, h.posting.start)
+				span.End2 = max(span.End2, h.posting.end)
+				continue
+			}
+			match.Spans = append(match.Spans, Span{Start1: h.start, End1: h.end, Start2: h.posting.start, End2: h.posting.end})
+		}
+		result = append(result, match)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Entry.Name < result[j].Entry.Name })
+	return result
+}
+
+func lastSpan(spans []Span) *Span {
+	if len(spans) == 0 {
+		return nil
+	}
+	return &spans[len(spans)-1]
+}
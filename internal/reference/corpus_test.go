@@ -0,0 +1,111 @@
+package reference
+
+import (
+	"CodeBorrowing/internal/fingerprint"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	bubbleSort = "static void BubbleSort(int[] items) {\n" +
+		"    for (int i = 0; i < items.Length - 1; i++) {\n" +
+		"        for (int j = 0; j < items.Length - i - 1; j++) {\n" +
+		"            if (items[j] > items[j + 1]) {\n" +
+		"                (items[j], items[j + 1]) = (items[j + 1], items[j]);\n" +
+		"            }\n" +
+		"        }\n" +
+		"    }\n" +
+		"}\n"
+	binarySearch = "static int BinarySearch(int[] sorted, int target) {\n" +
+		"    int low = 0, high = sorted.Length - 1;\n" +
+		"    while (low <= high) {\n" +
+		"        int middle = low + (high - low) / 2;\n" +
+		"        if (sorted[middle] == target) return middle;\n" +
+		"        if (sorted[middle] < target) low = middle + 1; else high = middle - 1;\n" +
+		"    }\n" +
+		"    return -1;\n" +
+		"}\n"
+)
+
+// testOptions take every k-gram long enough not to match common short code,
+// so spans cover whole functions.
+var testOptions = fingerprint.Options{K: 20, Window: 1}
+
+// loadTestCorpus writes files to a temp directory and loads them as a corpus.
+func loadTestCorpus(t *testing.T, files map[string]string) *Corpus {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	corpus, err := Load("books", root, testOptions, "csharp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return corpus
+}
+
+func TestLoad(t *testing.T) {
+	corpus := loadTestCorpus(t, map[string]string{
+		"algorithms/sort.cs": bubbleSort,
+		"search.cs":          binarySearch,
+		"readme.txt":         bubbleSort,
+	})
+	if corpus.Name() != "books" || corpus.Len() != 2 {
+		t.Errorf("got corpus %q of %d entries, want \"books\" of 2", corpus.Name(), corpus.Len())
+	}
+}
+
+func TestFind(t *testing.T) {
+	padding := strings.Repeat("// Nothing to see here.\n", 10)
+	corpus := loadTestCorpus(t, map[string]string{
+		"algorithms.cs": bubbleSort + padding + binarySearch, // Search is on lines 20-28.
+		"search.cs":     binarySearch,
+	})
+
+	tests := []struct {
+		name  string
+		file  string
+		want  map[string][]Span
+		lines map[string]int // Covered lines of the file by entries.
+	}{
+		{
+			name: "reordered functions",
+			file: binarySearch + bubbleSort,
+			want: map[string][]Span{
+				"algorithms.cs": {{Start1: 1, End1: 9, Start2: 20, End2: 28}, {Start1: 10, End1: 18, Start2: 1, End2: 9}},
+				"search.cs":     {{Start1: 1, End1: 9, Start2: 1, End2: 9}},
+			},
+		},
+		{
+			name: "own code",
+			file: "class Program {\n    static void Main() {\n        Console.WriteLine(\"Hello, World!\");\n    }\n}\n",
+			want: map[string][]Span{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string][]Span)
+			for _, match := range corpus.Find("a.cs", []byte(tt.file)) {
+				got[match.Entry.Name] = match.Spans
+				if len(match.Covered) == 0 || len(match.EntryCovered) == 0 {
+					t.Errorf("entry %q has no covered lines", match.Entry.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got spans %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package structure
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"CodeBorrowing/internal/tokenize"
+)
+
+// Version of the comparison, which changes with results of the same works.
+const Version = 1
+
+// Options of the structural comparison.
+type Options struct {
+	MinTokens int // Min size of matched subtrees in tokens.
+}
+
+// Similarities are fractions of works covered by matched subtrees, from 0 to 1.
+type Similarities struct {
+	Avg float64 `json:"AVG"`
+	Max float64 `json:"MAX"`
+}
+
+// Match is a pair of matched spans of files, lines start from 1.
+type Match struct {
+	File1  string `json:"file1"`
+	File2  string `json:"file2"`
+	Start1 int    `json:"start1"`
+	Start2 int    `json:"start2"`
+	End1   int    `json:"end1"`
+	End2   int    `json:"end2"`
+}
+
+// Comparison of two works in the shape of checker results.
+type Comparison struct {
+	ID1          string       `json:"id1"`
+	ID2          string       `json:"id2"`
+	Similarities Similarities `json:"similarities"`
+	Matches      []Match      `json:"matches"`
+}
+
+// Swap returns the comparison with the works in the other order.
+func (c Comparison) Swap() Comparison {
+	swapped := Comparison{ID1: c.ID2, ID2: c.ID1, Similarities: c.Similarities, Matches: make([]Match, 0, len(c.Matches))}
+	for _, m := range c.Matches {
+		swapped.Matches = append(swapped.Matches, Match{
+			File1: m.File2, File2: m.File1,
+			Start1: m.Start2, Start2: m.Start1,
+			End1: m.End2, End2: m.End1,
+		})
+	}
+	return swapped
+}
+
+// File is the syntax tree of a source file.
+type File struct {
+	Path string // Path relative to the work directory with "/" as a separator.
+	Root *Node
+}
+
+// Work is a parsed submission.
+type Work struct {
+	ID    string // Name of the work directory.
+	Files []File
+	Size  int // Count of tokens in all files.
+}
+
+// LoadWork parses source files of the work directory.
+// Files of languages without tokenizers are skipped.
+func LoadWork(dir string) (*Work, error) {
+	work := &Work{ID: filepath.Base(dir)}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		language, ok := tokenize.ForFile(path)
+		if !ok {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		root := Parse(language.Lexer(content, tokenize.Options{}), language.Name)
+		work.Files = append(work.Files, File{Path: filepath.ToSlash(rel), Root: root})
+		work.Size += root.Size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return work, nil
+}
+
+// subtree is a node of a file of the work.
+type subtree struct {
+	file string
+	node *Node
+}
+
+// Compare finds the largest subtrees of the new work, which have equal subtrees in the old work.
+// Each old subtree matches once. Returns false, if nothing matched.
+func Compare(newWork *Work, oldWork *Work, options Options) (Comparison, bool) {
+	index := make(map[uint64][]subtree)
+	for _, file := range oldWork.Files {
+		file.Root.Walk(func(node *Node) bool {
+			if node.Size < options.MinTokens {
+				return false
+			}
+			index[node.Hash] = append(index[node.Hash], subtree{file: file.Path, node: node})
+			return true
+		})
+	}
+
+	used := make(map[*Node]struct{})
+	comparison := Comparison{ID1: newWork.ID, ID2: oldWork.ID}
+	matched := 0
+
+	for _, file := range newWork.Files {
+		file.Root.Walk(func(node *Node) bool {
+			if node.Size < options.MinTokens {
+				return false
+			}
+			for _, old := range index[node.Hash] {
+				if _, ok := used[old.node]; ok {
+					continue
+				}
+				used[old.node] = struct{}{}
+				matched += node.Size
+				comparison.Matches = append(comparison.Matches, Match{
+					File1: file.Path, Start1: node.StartLine, End1: node.EndLine,
+					File2: old.file, Start2: old.node.StartLine, End2: old.node.EndLine,
+				})
+				return false
+			}
+			return true
+		})
+	}
+	if len(comparison.Matches) == 0 {
+		return comparison, false
+	}
+
+	// Old subtrees may be nested, so the covered size counts the outermost of them.
+	oldMatched := 0
+	for _, file := range oldWork.Files {
+		file.Root.Walk(func(node *Node) bool {
+			if _, ok := used[node]; ok {
+				oldMatched += node.Size
+				return false
+			}
+			return true
+		})
+	}
+
+	share1 := share(matched, newWork.Size)
+	share2 := share(oldMatched, oldWork.Size)
+	comparison.Similarities = Similarities{Avg: (share1 + share2) / 2, Max: max(share1, share2)}
+	return comparison, true
+}
+
+func share(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return min(float64(part)/float64(total), 1)
+}
@@ -0,0 +1,59 @@
+package structure
+
+import "testing"
+
+func work(t *testing.T, id string, src string) *Work {
+	t.Helper()
+	root := parse(t, "csharp", src)
+	return &Work{ID: id, Files: []File{{Path: "a.cs", Root: root}}, Size: root.Size}
+}
+
+func TestCompare(t *testing.T) {
+	const original = "class A {\n" +
+		"int Sum(int[] a) { int s = 0; foreach (var x in a) { s += x; } return s; }\n" +
+		"int Max(int a, int b) { if (a > b) { return a; } else { return b; } }\n" +
+		"}\n"
+
+	tests := []struct {
+		name    string
+		other   string
+		matched bool
+		full    bool
+	}{
+		{"same", original, true, true},
+		{"renamed and reordered",
+			"class B {\n" +
+				"int Big(int p, int q) { if (p > q) { return p; } else { return q; } }\n" +
+				"int Total(int[] v) { int t = 0; foreach (var y in v) { t += y; } return t; }\n" +
+				"}\n", true, true},
+		{"one method",
+			"class C {\n" +
+				"int Sum(int[] a) { int s = 0; foreach (var x in a) { s += x; } return s; }\n" +
+				"void Print() { Console.WriteLine(1); }\n" +
+				"}\n", true, false},
+		{"unrelated", "class D {\nvoid Run() { while (true) { Step(); } }\n}\n", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison, ok := Compare(work(t, "1", original), work(t, "2", tt.other), Options{MinTokens: 8})
+			if ok != tt.matched {
+				t.Fatalf("Compare() matched: %v, want %v", ok, tt.matched)
+			}
+			if !ok {
+				return
+			}
+			if comparison.ID1 != "1" || comparison.ID2 != "2" {
+				t.Errorf("got works %s and %s", comparison.ID1, comparison.ID2)
+			}
+			if full := comparison.Similarities.Max == 1; full != tt.full {
+				t.Errorf("similarities %+v, full: %v, want %v", comparison.Similarities, full, tt.full)
+			}
+			for _, match := range comparison.Matches {
+				if match.Start1 < 1 || match.End1 < match.Start1 || match.Start2 < 1 || match.End2 < match.Start2 {
+					t.Errorf("bad lines of the match %+v", match)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package structure
+
+import "CodeBorrowing/internal/tokenize"
+
+// Languages, where blocks are made by indentation instead of braces.
+var indentedLanguages = map[string]struct{}{"python": {}}
+
+// Keywords, which begin blocks of statements rather than functions.
+var blockKeywords = map[string]struct{}{
+	"try": {}, "catch": {}, "finally": {}, "using": {}, "lock": {}, "synchronized": {}, "fixed": {},
+	"unsafe": {}, "checked": {}, "unchecked": {}, "case": {}, "default": {}, "defer": {}, "go": {}, "select": {},
+}
+
+// Keywords, which make the block a type.
+var typeKeywords = map[string]struct{}{
+	"class": {}, "struct": {}, "interface": {}, "enum": {}, "namespace": {}, "record": {}, "union": {},
+}
+
+// Tokens, which make a brace a part of the expression: initializers, lambdas and anonymous classes.
+var expressionTokens = map[string]struct{}{
+	"=": {}, ":=": {}, "=>": {}, "->": {}, "return": {}, "new": {},
+}
+
+// Parse builds the simplified syntax tree of the file from its tokens.
+func Parse(tokens []tokenize.Token, language string) *Node {
+	if _, ok := indentedLanguages[language]; ok {
+		p := &indentParser{lines: logicalLines(tokens)}
+		return newNode(KindFile, nil, p.parseSuite(-1))
+	}
+
+	p := &braceParser{tokens: tokens}
+	var children []*Node
+	for p.pos < len(p.tokens) {
+		children = append(children, p.parseBlock()...)
+		// Unbalanced closing braces are skipped.
+		p.pos++
+	}
+	return newNode(KindFile, nil, children)
+}
+
+// braceParser parses languages with C syntax of blocks.
+type braceParser struct {
+	tokens []tokenize.Token
+	pos    int
+}
+
+func (p *braceParser) is(raw string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].Raw == raw
+}
+
+// parseBlock parses statements up to the closing brace or the end.
+func (p *braceParser) parseBlock() []*Node {
+	var children []*Node
+	for p.pos < len(p.tokens) && !p.is("}") {
+		children = append(children, p.parseStatement()...)
+	}
+	return children
+}
+
+// parseBody parses the block in braces or the single statement.
+func (p *braceParser) parseBody() []*Node {
+	switch {
+	case p.is("{"):
+		p.pos++
+		children := p.parseBlock()
+		if p.is("}") {
+			p.pos++
+		}
+		return children
+	case p.is(";"):
+		p.pos++
+		return nil
+	case p.pos < len(p.tokens):
+		return p.parseStatement()
+	}
+	return nil
+}
+
+// parseCondition returns tokens in parentheses or, if there are none, up to the opening brace.
+func (p *braceParser) parseCondition() []tokenize.Token {
+	start, depth := p.pos, 0
+	parenthesized := p.is("(")
+
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch p.tokens[p.pos].Raw {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+			if parenthesized && depth == 0 {
+				p.pos++
+				return p.tokens[start+1 : p.pos-1]
+			}
+		case "{", ";", "}":
+			if !parenthesized && depth == 0 {
+				return p.tokens[start:p.pos]
+			}
+		}
+	}
+	return p.tokens[start:p.pos]
+}
+
+// parseStatement parses the statement, whose last node spans up to its last token,
+// so the closing brace is in its lines.
+func (p *braceParser) parseStatement() []*Node {
+	nodes := p.parseNodes()
+	if len(nodes) > 0 && p.pos > 0 {
+		last := nodes[len(nodes)-1]
+		last.span(last.StartLine, p.tokens[p.pos-1].Line)
+	}
+	return nodes
+}
+
+func (p *braceParser) parseNodes() []*Node {
+	token := p.tokens[p.pos]
+	if token.Kind != tokenize.Keyword {
+		return p.parseSimple()
+	}
+
+	switch token.Raw {
+	case "if":
+		p.pos++
+		condition := p.parseCondition()
+		then := newNode(KindBlock, nil, p.parseBody())
+		if !p.is("else") {
+			return []*Node{newNode(KindBranch, condition, []*Node{then})}
+		}
+		p.pos++
+		otherwise := newNode(KindBlock, nil, p.parseBody())
+		return []*Node{newNode(KindBranch, conditionTokens(condition), []*Node{then, otherwise})}
+
+	case "while":
+		p.pos++
+		condition := p.parseCondition()
+		return []*Node{newNode(KindLoop, condition, p.parseBody())}
+
+	case "do":
+		p.pos++
+		body := p.parseBody()
+		var condition []tokenize.Token
+		if p.is("while") {
+			p.pos++
+			condition = p.parseCondition()
+		}
+		if p.is(";") {
+			p.pos++
+		}
+		return []*Node{newNode(KindLoop, condition, body)}
+
+	case "for", "foreach":
+		p.pos++
+		parts := splitTokens(p.parseCondition(), ";")
+		body := p.parseBody()
+		if len(parts) != 3 {
+			return []*Node{newNode(KindLoop, joinTokens(parts), body)}
+		}
+
+		// The loop with a counter is the same as its initializer and the while loop
+		// with the step at the end of the body.
+		var nodes []*Node
+		if len(parts[0]) > 0 {
+			nodes = append(nodes, newNode(KindStatement, parts[0], nil))
+		}
+		if len(parts[2]) > 0 {
+			body = append(body, newNode(KindStatement, parts[2], nil))
+		}
+		return append(nodes, newNode(KindLoop, parts[1], body))
+
+	case "switch":
+		p.pos++
+		condition := p.parseCondition()
+		return []*Node{newNode(KindBranch, condition, p.parseBody())}
+	}
+
+	return p.parseSimple()
+}
+
+// parseSimple parses the statement up to the semicolon or the declaration with its block.
+func (p *braceParser) parseSimple() []*Node {
+	var header []tokenize.Token
+	depth := 0
+
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		if depth == 0 {
+			switch token.Raw {
+			case ";":
+				p.pos++
+				return statement(header)
+			case "}":
+				return statement(header)
+			case "{":
+				if isExpression(header) {
+					header = append(header, p.skipBraces()...)
+					continue
+				}
+				p.pos++
+				children := p.parseBlock()
+				if p.is("}") {
+					p.pos++
+				}
+				return []*Node{newNode(declarationKind(header), header, children)}
+			}
+		}
+
+		switch token.Raw {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth = max(depth-1, 0)
+		}
+		header = append(header, token)
+		p.pos++
+	}
+
+	return statement(header)
+}
+
+// skipBraces returns tokens of the balanced braces at the position.
+func (p *braceParser) skipBraces() []tokenize.Token {
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch p.tokens[p.pos].Raw {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.tokens[start:p.pos]
+			}
+		}
+	}
+	return p.tokens[start:p.pos]
+}
+
+// statement makes the statement node, if it has tokens.
+func statement(tokens []tokenize.Token) []*Node {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return []*Node{newNode(KindStatement, tokens, nil)}
+}
+
+func isExpression(header []tokenize.Token) bool {
+	for _, token := range header {
+		if _, ok := expressionTokens[token.Raw]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// declarationKind returns the kind of the block by its header.
+func declarationKind(header []tokenize.Token) string {
+	if len(header) > 0 {
+		if _, ok := blockKeywords[header[0].Raw]; ok {
+			return KindBlock
+		}
+	}
+
+	function := false
+	for _, token := range header {
+		if _, ok := typeKeywords[token.Raw]; ok && token.Kind == tokenize.Keyword {
+			return KindType
+		}
+		function = function || token.Raw == "(" || token.Raw == "func"
+	}
+	if function {
+		return KindFunction
+	}
+	return KindBlock
+}
+
+// splitTokens splits tokens by the separator outside of brackets.
+func splitTokens(tokens []tokenize.Token, separator string) [][]tokenize.Token {
+	var parts [][]tokenize.Token
+	start, depth := 0, 0
+	for i, token := range tokens {
+		switch token.Raw {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case separator:
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+func joinTokens(parts [][]tokenize.Token) []tokenize.Token {
+	var tokens []tokenize.Token
+	for _, part := range parts {
+		tokens = append(tokens, part...)
+	}
+	return tokens
+}
+
+// logicalLine is a statement of an indented language with the column of its first token.
+type logicalLine struct {
+	tokens []tokenize.Token
+	indent int
+}
+
+// logicalLines splits tokens into statements: lines outside of brackets and parts between semicolons.
+func logicalLines(tokens []tokenize.Token) []logicalLine {
+	var lines []logicalLine
+	depth, lastLine := 0, 0
+
+	for _, token := range tokens {
+		if depth == 0 && token.Raw == ";" {
+			lastLine = 0
+			continue
+		}
+		if depth == 0 && (token.Line > lastLine || lastLine == 0) {
+			lines = append(lines, logicalLine{indent: token.Column})
+		}
+		line := &lines[len(lines)-1]
+		line.tokens = append(line.tokens, token)
+
+		switch token.Raw {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth = max(depth-1, 0)
+		}
+		lastLine = token.Line
+		for i := 0; i < len(token.Raw); i++ {
+			if token.Raw[i] == '\n' {
+				lastLine++
+			}
+		}
+	}
+
+	return lines
+}
+
+// indentParser parses languages with blocks made by indentation.
+type indentParser struct {
+	lines []logicalLine
+	pos   int
+}
+
+// parseSuite parses statements indented deeper than the parent.
+func (p *indentParser) parseSuite(parentIndent int) []*Node {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parentIndent {
+		return nil
+	}
+
+	indent := p.lines[p.pos].indent
+	var children []*Node
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > parentIndent {
+		if p.lines[p.pos].indent > indent {
+			// Unexpected indentation continues the previous statement.
+			children = append(children, p.parseSuite(indent)...)
+			continue
+		}
+		children = append(children, p.parseLine()...)
+	}
+	return children
+}
+
+// parseLine parses the statement at the position with its nested block.
+func (p *indentParser) parseLine() []*Node {
+	line := p.lines[p.pos]
+	p.pos++
+
+	header, body, compound := p.splitCompound(line)
+	if !compound {
+		return statement(line.tokens)
+	}
+
+	keyword := header[0].Raw
+	if keyword == "async" && len(header) > 1 {
+		keyword = header[1].Raw
+	}
+
+	switch keyword {
+	case "if", "elif":
+		then := newNode(KindBlock, nil, body)
+		next, ok := p.continuation(line.indent)
+		if !ok {
+			return []*Node{newNode(KindBranch, header[1:], []*Node{then})}
+		}
+
+		var otherwise *Node
+		if next == "elif" {
+			otherwise = newNode(KindBlock, nil, p.parseLine())
+		} else {
+			elseLine := p.lines[p.pos]
+			p.pos++
+			_, elseBody, _ := p.splitCompound(elseLine)
+			otherwise = newNode(KindBlock, nil, elseBody)
+		}
+		return []*Node{newNode(KindBranch, conditionTokens(header[1:]), []*Node{then, otherwise})}
+	case "for", "while":
+		return []*Node{newNode(KindLoop, header[1:], body)}
+	case "def":
+		return []*Node{newNode(KindFunction, header, body)}
+	case "class":
+		return []*Node{newNode(KindType, header, body)}
+	}
+	return []*Node{newNode(KindBlock, header, body)}
+}
+
+// continuation returns the keyword of the elif or else line, which continues the condition.
+func (p *indentParser) continuation(indent int) (string, bool) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent {
+		return "", false
+	}
+	keyword := p.lines[p.pos].tokens[0].Raw
+	return keyword, keyword == "elif" || keyword == "else"
+}
+
+// splitCompound splits the compound statement into the header up to the colon and the body:
+// the rest of the line or the indented block.
+func (p *indentParser) splitCompound(line logicalLine) ([]tokenize.Token, []*Node, bool) {
+	first := line.tokens[0]
+	if first.Kind != tokenize.Keyword && first.Raw != "async" {
+		return nil, nil, false
+	}
+	switch first.Raw {
+	case "if", "elif", "else", "for", "while", "def", "class", "try", "except", "finally", "with", "async":
+	default:
+		return nil, nil, false
+	}
+
+	depth := 0
+	for i, token := range line.tokens {
+		switch token.Raw {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case ":":
+			if depth != 0 {
+				continue
+			}
+			if i+1 < len(line.tokens) {
+				return line.tokens[:i], statement(line.tokens[i+1:]), true
+			}
+			return line.tokens[:i], p.parseSuite(line.indent), true
+		}
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,79 @@
+package structure
+
+import (
+	"CodeBorrowing/internal/tokenize"
+	"testing"
+)
+
+func parse(t *testing.T, language string, src string) *Node {
+	t.Helper()
+	lang, ok := tokenize.ForLanguage(language)
+	if !ok {
+		t.Fatalf("language %q is not registered", language)
+	}
+	return Parse(lang.Lexer([]byte(src), tokenize.Options{}), language)
+}
+
+func TestParseHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		a, b     string
+		equal    bool
+	}{
+		{"renamed", "csharp",
+			"class A { int F(int x) { return x + 1; } }",
+			"class B { int G(int y) { return y + 2; } }", true},
+		{"reordered methods", "csharp",
+			"class A { void F() { x = 1; } int G() { return 2; } }",
+			"class A { int G() { return 2; } void F() { x = 1; } }", true},
+		{"swapped branches", "csharp",
+			"void F() { if (a) { x = 1; } else { return; } }",
+			"void F() { if (a) { return; } else { x = 1; } }", true},
+		{"reordered statements", "csharp",
+			"void F() { x = 1; return; }",
+			"void F() { return; x = 1; }", false},
+		{"loop instead of branch", "java",
+			"class A { void f() { while (a) { b(); } } }",
+			"class A { void f() { if (a) { b(); } } }", false},
+		{"reordered functions", "python",
+			"def f():\n    return 1\n\ndef g(x):\n    x += 1\n",
+			"def g(y):\n    y += 1\n\ndef f():\n    return 2\n", true},
+		{"nesting", "python",
+			"def f():\n    if a:\n        b()\n        c()\n",
+			"def f():\n    if a:\n        b()\n    c()\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := parse(t, tt.language, tt.a), parse(t, tt.language, tt.b)
+			if (a.Hash == b.Hash) != tt.equal {
+				t.Errorf("equal hashes: %v, want %v", a.Hash == b.Hash, tt.equal)
+			}
+			if a.Size != b.Size && tt.equal {
+				t.Errorf("sizes %d and %d differ", a.Size, b.Size)
+			}
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	root := parse(t, "csharp", "class A\n{\n    void F()\n    {\n        x = 1;\n    }\n}\n")
+	if root.StartLine != 1 || root.EndLine != 7 {
+		t.Errorf("file spans lines %d-%d, want 1-7", root.StartLine, root.EndLine)
+	}
+
+	var function *Node
+	root.Walk(func(node *Node) bool {
+		if node.Kind == KindFunction {
+			function = node
+		}
+		return function == nil
+	})
+	if function == nil {
+		t.Fatal("function is not found")
+	}
+	if function.StartLine != 3 || function.EndLine != 6 {
+		t.Errorf("function spans lines %d-%d, want 3-6", function.StartLine, function.EndLine)
+	}
+}
@@ -0,0 +1,123 @@
+package structure
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"CodeBorrowing/internal/tokenize"
+)
+
+// Kinds of nodes of the simplified syntax tree.
+const (
+	KindFile      = "file"
+	KindType      = "type"     // Classes, structures, interfaces, enums and namespaces.
+	KindFunction  = "function" // Functions, methods and constructors.
+	KindBlock     = "block"    // Other blocks: bodies, try, properties.
+	KindLoop      = "loop"     // for, foreach, while and do loops.
+	KindBranch    = "branch"   // if with optional else and switch.
+	KindStatement = "statement"
+)
+
+// Node is a subtree of the simplified syntax tree.
+//
+// The hash of a node does not depend on names and literals. Children of files and types
+// and branches of conditions are hashed in sorted order, so reordered members
+// and swapped branches give the same hash.
+type Node struct {
+	Kind      string
+	Label     string // Normalized tokens of the header or of the statement.
+	Hash      uint64
+	Size      int // Count of tokens in the subtree.
+	StartLine int
+	EndLine   int
+	Children  []*Node
+}
+
+// newNode makes the node of the header tokens and the children.
+func newNode(kind string, header []tokenize.Token, children []*Node) *Node {
+	node := &Node{Kind: kind, Label: label(header), Size: len(header), Children: children}
+
+	for _, token := range header {
+		node.span(token.Line, token.Line+strings.Count(token.Raw, "\n"))
+	}
+	for _, child := range children {
+		node.Size += child.Size
+		node.span(child.StartLine, child.EndLine)
+	}
+
+	node.Hash = node.hash()
+	return node
+}
+
+// span extends lines of the node.
+func (n *Node) span(start int, end int) {
+	if n.StartLine == 0 || start < n.StartLine {
+		n.StartLine = start
+	}
+	n.EndLine = max(n.EndLine, end)
+}
+
+func (n *Node) hash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(n.Kind))
+	h.Write([]byte{0})
+	h.Write([]byte(n.Label))
+	h.Write([]byte{0})
+
+	hashes := make([]uint64, 0, len(n.Children))
+	for _, child := range n.Children {
+		hashes = append(hashes, child.Hash)
+	}
+	if n.Kind == KindFile || n.Kind == KindType || n.Kind == KindBranch {
+		sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	}
+
+	var buf [8]byte
+	for _, hash := range hashes {
+		binary.LittleEndian.PutUint64(buf[:], hash)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// Walk calls the function for the node and its descendants, while it returns true.
+func (n *Node) Walk(f func(node *Node) bool) {
+	if !f(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(f)
+	}
+}
+
+// label joins normalized texts of tokens.
+func label(tokens []tokenize.Token) string {
+	texts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		texts = append(texts, token.Text)
+	}
+	return strings.Join(texts, " ")
+}
+
+// Negations in conditions, which are removed or replaced by their opposites,
+// so a condition and its negation give the same label, when branches are swapped.
+var negations = map[string]string{
+	"!": "", "not": "", "!=": "==", ">=": "<", "<=": ">",
+}
+
+// conditionTokens returns the condition without negations.
+func conditionTokens(tokens []tokenize.Token) []tokenize.Token {
+	result := make([]tokenize.Token, 0, len(tokens))
+	for _, token := range tokens {
+		if opposite, ok := negations[token.Text]; ok {
+			if opposite == "" {
+				continue
+			}
+			token.Text = opposite
+		}
+		result = append(result, token)
+	}
+	return result
+}
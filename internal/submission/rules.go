@@ -0,0 +1,153 @@
+package submission
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+const binarySniffSize = 8000
+
+// Reasons of excluding files.
+const (
+	ReasonExcluded    = "excluded"
+	ReasonNotIncluded = "not included"
+	ReasonTooLarge    = "too large"
+	ReasonBinary      = "binary"
+	ReasonBadNotebook = "bad notebook"
+)
+
+// Rules decide which files of a submission are compared.
+//
+// Patterns use "/" as a separator. A pattern ending with "/" matches a directory
+// at any depth, a pattern without "/" matches a file name at any depth,
+// other patterns match the whole path, where "**" matches any count of directories.
+type Rules struct {
+	Include     []string // A file must match one of them (empty - all files).
+	Exclude     []string // A file must not match any of them.
+	MaxFileSize uint64   // Max size of a file in bytes (0 - unlimited).
+}
+
+// Files of tools, builds and dependencies, which are never written by students.
+var commonExclude = []string{
+	".git/", ".svn/", ".vs/", ".idea/", ".vscode/", "__MACOSX/", "node_modules/",
+	".DS_Store", "Thumbs.db", "desktop.ini",
+	"*.exe", "*.dll", "*.so", "*.dylib", "*.o", "*.obj", "*.a", "*.lib", "*.pdb", "*.class", "*.jar", "*.pyc",
+	"*.zip", "*.rar", "*.7z", "*.tar", "*.gz",
+	"*.png", "*.jpg", "*.jpeg", "*.gif", "*.bmp", "*.ico", "*.pdf", "*.doc", "*.docx",
+}
+
+var languageInclude = map[string][]string{
+	"csharp": {"*.cs"},
+	"java":   {"*.java"},
+	"python": {"*.py"},
+	"cpp":    {"*.c", "*.h", "*.cpp", "*.hpp", "*.cc", "*.hh", "*.cxx", "*.hxx"},
+	"go":     {"*.go"},
+}
+
+var languageExclude = map[string][]string{
+	"csharp": {"bin/", "obj/", "packages/", "*.suo", "*.user", "*.cache", "*.Designer.cs", "AssemblyInfo.cs"},
+	"java":   {"target/", "build/", "out/", ".gradle/"},
+	"python": {"__pycache__/", "venv/", ".venv/", "*.egg-info/"},
+	"cpp":    {"Debug/", "Release/", "x64/", "build/", "cmake-build-*/"},
+	"go":     {"vendor/"},
+}
+
+// DefaultRules returns rules for submissions in the language.
+// Unknown languages include all files except common build and tool files.
+func DefaultRules(language string) Rules {
+	return Rules{
+		Include: append([]string{}, languageInclude[language]...),
+		Exclude: append(append([]string{}, commonExclude...), languageExclude[language]...),
+	}
+}
+
+// CheckPath checks the file by its path. Returns the reason of exclusion or an empty string.
+func (r Rules) CheckPath(name string) string {
+	name = strings.TrimPrefix(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/")
+
+	for _, pattern := range r.Exclude {
+		if matchPattern(pattern, name) {
+			return ReasonExcluded
+		}
+	}
+
+	if len(r.Include) == 0 {
+		return ""
+	}
+	for _, pattern := range r.Include {
+		if matchPattern(pattern, name) {
+			return ""
+		}
+	}
+	return ReasonNotIncluded
+}
+
+// CheckContent checks the content of the file. Returns the reason of exclusion or an empty string.
+func (r Rules) CheckContent(content []byte) string {
+	if r.MaxFileSize != 0 && uint64(len(content)) > r.MaxFileSize {
+		return ReasonTooLarge
+	}
+	if IsBinary(content) {
+		return ReasonBinary
+	}
+	return ""
+}
+
+// IsBinary detects binary content by NUL bytes in its beginning.
+// UTF-16 text with a byte order mark is not binary.
+func IsBinary(content []byte) bool {
+	if bytes.HasPrefix(content, []byte{0xFF, 0xFE}) || bytes.HasPrefix(content, []byte{0xFE, 0xFF}) {
+		return false
+	}
+	return bytes.IndexByte(content[:min(len(content), binarySniffSize)], 0) != -1
+}
+
+func matchPattern(pattern string, name string) bool {
+	segments := strings.Split(name, "/")
+
+	// Directory at any depth.
+	if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+		if strings.Contains(dir, "/") {
+			return matchSegments(strings.Split(dir+"/**", "/"), segments)
+		}
+		for _, segment := range segments[:len(segments)-1] {
+			if ok, _ := path.Match(dir, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	// File name at any depth.
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, segments[len(segments)-1])
+		return ok
+	}
+
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), segments)
+}
+
+// matchSegments matches the path by segments, "**" matches any count of segments.
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,68 @@
+package task
+
+import (
+	"CodeBorrowing/internal/router"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const urlGetBaseCode = "/api/basecode"
+
+func (s *service) getBaseCodeId(eventId uint64) ([]uint64, error) {
+	req, err := router.NewRequest(http.MethodGet, urlGetBaseCode, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	q.Add("id", strconv.FormatUint(eventId, 10))
+	req.URL.RawQuery = q.Encode()
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(string(body))
+	}
+
+	var works WorksIdDTO
+	if err = json.Unmarshal(body, &works); err != nil {
+		return nil, err
+	}
+
+	return works.List, nil
+}
+
+// GetBaseCode returns base code archives of the event (e.g. the assignment template).
+// They are cached as works and pinned with works of the event.
+func (s *service) GetBaseCode(eventId uint64) ([]WorkEntry, error) {
+	ids, err := s.getBaseCodeId(eventId)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	s.pins.add(eventId, ids)
+
+	works, notFound := s.getWorksEntry(ids)
+	if len(notFound) == 0 {
+		return works, nil
+	}
+
+	downloaded, err := s.downloadWorks(notFound)
+	if err != nil {
+		return works, err
+	}
+
+	return append(works, downloaded...), nil
+}
@@ -0,0 +1,126 @@
+package task
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const blobTempPrefix = "tmp-"
+
+// blobStore keeps extracted files by the hash of their content.
+// Directories of works consist of hard links to the blobs.
+type blobStore struct {
+	root string
+}
+
+func newBlobStore(root string) (*blobStore, error) {
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &blobStore{root: root}, nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (b *blobStore) path(hash string) string {
+	return filepath.Join(b.root, hash[:2], hash)
+}
+
+func (b *blobStore) exists(hash string) bool {
+	_, err := os.Stat(b.path(hash))
+	return err == nil
+}
+
+// put saves the content to the store and returns its hash and size.
+func (b *blobStore) put(r io.Reader) (string, uint64, error) {
+	tmp, err := os.CreateTemp(b.root, blobTempPrefix+"*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	if b.exists(hash) {
+		return hash, uint64(size), nil
+	}
+
+	if err = os.MkdirAll(filepath.Dir(b.path(hash)), os.ModePerm); err != nil {
+		return "", 0, err
+	}
+	if err = os.Rename(tmp.Name(), b.path(hash)); err != nil {
+		return "", 0, err
+	}
+
+	return hash, uint64(size), nil
+}
+
+// link makes the blob available by the path.
+// The content is copied, if the file system does not support hard links.
+func (b *blobStore) link(hash string, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := os.Link(b.path(hash), path); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(b.path(hash))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (b *blobStore) remove(hash string) error {
+	err := os.Remove(b.path(hash))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// removeUnknown removes files, which are not blobs of the storage,
+// including temporary files of interrupted extractions.
+func (b *blobStore) removeUnknown(known map[string]struct{}) error {
+	return filepath.WalkDir(b.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		name := d.Name()
+		if _, ok := known[name]; ok && !strings.HasPrefix(name, blobTempPrefix) {
+			return nil
+		}
+		return os.Remove(path)
+	})
+}
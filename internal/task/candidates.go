@@ -0,0 +1,65 @@
+package task
+
+import (
+	"CodeBorrowing/internal/fingerprint"
+	"CodeBorrowing/internal/normalize"
+	"os"
+)
+
+// indexWork makes fingerprints of files of the work, which are not indexed yet.
+func (s *service) indexWork(work WorkEntry) error {
+	key := s.indexKey()
+	files, err := s.storage.GetUnindexedBlobs(work.Id, key)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(s.blobs.path(file.Hash))
+		if err != nil {
+			return err
+		}
+
+		fingerprints := fingerprint.Winnow(normalize.File(file.Path, content, s.language), s.fingerprint)
+		if err = s.storage.SaveFingerprints(file.Hash, key, fingerprints); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexKey identifies fingerprints made with the current options and normalization.
+func (s *service) indexKey() string {
+	return s.fingerprint.Key() + "-" + s.normKey()
+}
+
+// SelectCandidates returns up to count old works sharing the most fingerprints with the new work.
+// Works are indexed on demand, if they were cached before the index or with other options.
+func (s *service) SelectCandidates(newWork WorkEntry, oldWorks []WorkEntry, count uint64) ([]WorkEntry, error) {
+	byId := make(map[uint64]WorkEntry, len(oldWorks))
+	ids := make([]uint64, 0, len(oldWorks))
+
+	for _, work := range append([]WorkEntry{newWork}, oldWorks...) {
+		if err := s.indexWork(work); err != nil {
+			return nil, err
+		}
+		if work.Id != newWork.Id {
+			byId[work.Id] = work
+			ids = append(ids, work.Id)
+		}
+	}
+
+	candidates, err := s.storage.GetCandidates(newWork.Id, ids, count)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]WorkEntry, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, byId[candidate.WorkId])
+	}
+
+	s.logger.Debugf("Work %d: %d of %d old works selected by fingerprints", newWork.Id, len(result), len(oldWorks))
+	return result, nil
+}
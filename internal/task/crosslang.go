@@ -0,0 +1,241 @@
+package task
+
+import (
+	"CodeBorrowing/internal/tokenize"
+	"errors"
+	"hash/fnv"
+	"os"
+)
+
+var ErrInvalidCrossLanguage = errors.New("cross-language sequence length must be positive and min similarity in [0, 100]")
+
+// CrossLanguageOptions configure comparison of works written in different languages.
+type CrossLanguageOptions struct {
+	K             int     // Length of matched sequences of abstract tokens.
+	MinSimilarity float64 // Pairs with the lower max similarity in percents are not reported.
+}
+
+func (o CrossLanguageOptions) Validate() error {
+	if o.K <= 0 || !(0 <= o.MinSimilarity && o.MinSimilarity <= 100) {
+		return ErrInvalidCrossLanguage
+	}
+	return nil
+}
+
+// CrossLanguageResult contains comparisons of the new work with old works in other languages.
+type CrossLanguageResult struct {
+	Reports []ReportItem
+	Other   map[uint64]struct{} // Old works in other languages than the new one.
+}
+
+// abstractFile is the stream of abstract tokens of a work file.
+type abstractFile struct {
+	path   string
+	tokens []tokenize.Token
+}
+
+// abstractWork is a work in the abstract vocabulary.
+type abstractWork struct {
+	language string // The language of most of the code.
+	files    []abstractFile
+	size     int // Count of tokens in all files.
+}
+
+// loadAbstractWork maps files of the work to the abstract vocabulary.
+// Files of languages without tokenizers are skipped.
+func (s *service) loadAbstractWork(work WorkEntry) (abstractWork, error) {
+	result := abstractWork{}
+
+	files, err := s.storage.GetWorkFiles(work.Id)
+	if err != nil {
+		return result, err
+	}
+
+	sizes := make(map[string]uint64)
+	for _, file := range files {
+		language, ok := tokenize.ForFile(file.Path)
+		if !ok {
+			continue
+		}
+		content, err := os.ReadFile(s.blobs.path(file.Hash))
+		if err != nil {
+			return result, err
+		}
+
+		tokens := tokenize.Abstract(language.Lexer(content, tokenize.Options{}))
+		result.files = append(result.files, abstractFile{path: file.Path, tokens: tokens})
+		result.size += len(tokens)
+		sizes[language.Name] += file.Size
+	}
+
+	for language, size := range sizes {
+		if size > sizes[result.language] || size == sizes[result.language] && language < result.language {
+			result.language = language
+		}
+	}
+	return result, nil
+}
+
+// sequencePos is the start of a sequence of tokens in a file.
+type sequencePos struct {
+	file  int
+	token int
+}
+
+// hashSequences returns hashes of all sequences of k tokens of the file.
+func hashSequences(tokens []tokenize.Token, k int) []uint64 {
+	if len(tokens) < k {
+		return nil
+	}
+
+	hashes := make([]uint64, 0, len(tokens)-k+1)
+	for i := 0; i+k <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, token := range tokens[i : i+k] {
+			h.Write([]byte(token.Text))
+			h.Write([]byte{0})
+		}
+		hashes = append(hashes, h.Sum64())
+	}
+	return hashes
+}
+
+// compareAbstract finds sequences of abstract tokens of the new work, which are in the old work.
+// Sequences following each other in both works are merged into one match.
+// Similarity is the share of covered tokens of each work in percents.
+func compareAbstract(work1 WorkEntry, abstract1 abstractWork, work2 WorkEntry, abstract2 abstractWork, k int) ReportItem {
+	report := ReportItem{
+		Work1ID:       work1.Id,
+		Work2ID:       work2.Id,
+		Matches:       make([]MatchItem, 0),
+		CrossLanguage: true,
+		Work1Language: abstract1.language,
+		Work2Language: abstract2.language,
+	}
+
+	index := make(map[uint64][]sequencePos)
+	covered2 := make([][]bool, len(abstract2.files))
+	for i, file := range abstract2.files {
+		covered2[i] = make([]bool, len(file.tokens))
+		for j, hash := range hashSequences(file.tokens, k) {
+			index[hash] = append(index[hash], sequencePos{file: i, token: j})
+		}
+	}
+
+	covered1 := 0
+	for _, file1 := range abstract1.files {
+		hashes := hashSequences(file1.tokens, k)
+		covered := make([]bool, len(file1.tokens))
+
+		// The current run of sequences: its start in both files and its length.
+		var start1 int
+		var start2 sequencePos
+		length := 0
+
+		flush := func() {
+			if length == 0 {
+				return
+			}
+			file2 := abstract2.files[start2.file]
+			first1, last1 := file1.tokens[start1], file1.tokens[start1+length+k-2]
+			first2, last2 := file2.tokens[start2.token], file2.tokens[start2.token+length+k-2]
+			report.Matches = append(report.Matches, MatchItem{
+				Work1File:  file1.path,
+				Work1Start: uint64(first1.Line),
+				Work1Size:  lineCount(uint64(first1.Line), uint64(last1.Line)),
+				Work2File:  file2.path,
+				Work2Start: uint64(first2.Line),
+				Work2Size:  lineCount(uint64(first2.Line), uint64(last2.Line)),
+			})
+			length = 0
+		}
+
+		for i, hash := range hashes {
+			candidates := index[hash]
+			if len(candidates) == 0 {
+				flush()
+				continue
+			}
+
+			continued := false
+			if length > 0 && start1+length == i {
+				expected := sequencePos{file: start2.file, token: start2.token + length}
+				for _, candidate := range candidates {
+					if candidate == expected {
+						continued = true
+						break
+					}
+				}
+			}
+			if continued {
+				length++
+			} else {
+				flush()
+				start1, start2, length = i, candidates[0], 1
+			}
+
+			for j := 0; j < k; j++ {
+				covered[i+j] = true
+				covered2[start2.file][start2.token+length-1+j] = true
+			}
+		}
+		flush()
+
+		for _, c := range covered {
+			if c {
+				covered1++
+			}
+		}
+	}
+
+	covered2Count := 0
+	for _, file := range covered2 {
+		for _, c := range file {
+			if c {
+				covered2Count++
+			}
+		}
+	}
+
+	sim1 := float64(covered1) / float64(max(abstract1.size, 1)) * 100
+	sim2 := float64(covered2Count) / float64(max(abstract2.size, 1)) * 100
+	report.Avg = (sim1 + sim2) / 2
+	report.Max = max(sim1, sim2)
+	return report
+}
+
+// FindCrossLanguage compares the new work with old works written in other languages
+// through the abstract vocabulary of tokens. Works without known languages are skipped.
+func (s *service) FindCrossLanguage(newWork WorkEntry, oldWorks []WorkEntry) (CrossLanguageResult, error) {
+	result := CrossLanguageResult{
+		Reports: make([]ReportItem, 0),
+		Other:   make(map[uint64]struct{}),
+	}
+
+	newAbstract, err := s.loadAbstractWork(newWork)
+	if err != nil {
+		return result, err
+	}
+	if newAbstract.language == "" {
+		return result, nil
+	}
+
+	for _, oldWork := range oldWorks {
+		oldAbstract, err := s.loadAbstractWork(oldWork)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
+		if oldAbstract.language == "" || oldAbstract.language == newAbstract.language {
+			continue
+		}
+		result.Other[oldWork.Id] = struct{}{}
+
+		report := compareAbstract(newWork, newAbstract, oldWork, oldAbstract, s.crossLanguage.K)
+		if len(report.Matches) > 0 && report.Max >= s.crossLanguage.MinSimilarity {
+			result.Reports = append(result.Reports, report)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package task
+
+import (
+	"CodeBorrowing/internal/tokenize"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testAbstractFile makes the file of abstract tokens, one token per line.
+func testAbstractFile(path string, texts string) abstractFile {
+	file := abstractFile{path: path}
+	for i, text := range strings.Fields(texts) {
+		file.tokens = append(file.tokens, tokenize.Token{Text: text, Line: i + 1})
+	}
+	return file
+}
+
+func testAbstractWork(language string, files ...abstractFile) abstractWork {
+	work := abstractWork{language: language, files: files}
+	for _, file := range files {
+		work.size += len(file.tokens)
+	}
+	return work
+}
+
+func TestCompareAbstract(t *testing.T) {
+	tests := []struct {
+		name     string
+		new, old abstractWork
+		want     []MatchItem
+		avg, max float64
+	}{
+		{
+			name: "one run",
+			new:  testAbstractWork("java", testAbstractFile("A.java", "A B C D")),
+			old:  testAbstractWork("python", testAbstractFile("a.py", "A B C D")),
+			want: []MatchItem{{Work1File: "A.java", Work1Start: 1, Work1Size: 4, Work2File: "a.py", Work2Start: 1, Work2Size: 4}},
+			avg:  100, max: 100,
+		},
+		{
+			name: "runs in other places",
+			new:  testAbstractWork("java", testAbstractFile("A.java", "A B C D X Y Z")),
+			old: testAbstractWork("python",
+				testAbstractFile("a.py", "Q X Y Z"),
+				testAbstractFile("b.py", "A B C D")),
+			want: []MatchItem{
+				{Work1File: "A.java", Work1Start: 1, Work1Size: 4, Work2File: "b.py", Work2Start: 1, Work2Size: 4},
+				{Work1File: "A.java", Work1Start: 5, Work1Size: 3, Work2File: "a.py", Work2Start: 2, Work2Size: 3},
+			},
+			avg: 93.75, max: 100,
+		},
+		{
+			name: "partial",
+			new:  testAbstractWork("java", testAbstractFile("A.java", "A B C D E F")),
+			old:  testAbstractWork("python", testAbstractFile("a.py", "A B C Q")),
+			want: []MatchItem{{Work1File: "A.java", Work1Start: 1, Work1Size: 3, Work2File: "a.py", Work2Start: 1, Work2Size: 3}},
+			avg:  62.5, max: 75,
+		},
+		{
+			name: "shorter than the sequence",
+			new:  testAbstractWork("java", testAbstractFile("A.java", "A B")),
+			old:  testAbstractWork("python", testAbstractFile("a.py", "A B")),
+			want: []MatchItem{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := compareAbstract(WorkEntry{Id: 1}, tt.new, WorkEntry{Id: 2}, tt.old, 3)
+			if !reflect.DeepEqual(report.Matches, tt.want) {
+				t.Errorf("got matches %+v, want %+v", report.Matches, tt.want)
+			}
+			if report.Avg != tt.avg || report.Max != tt.max {
+				t.Errorf("got similarities %v and %v, want %v and %v", report.Avg, report.Max, tt.avg, tt.max)
+			}
+			if !report.CrossLanguage || report.Work1Language != "java" || report.Work2Language != "python" {
+				t.Errorf("got languages %q and %q of the cross-language report", report.Work1Language, report.Work2Language)
+			}
+		})
+	}
+}
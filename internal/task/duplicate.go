@@ -0,0 +1,182 @@
+package task
+
+import (
+	"CodeBorrowing/internal/normalize"
+	"fmt"
+	"os"
+)
+
+// Hash of files, which are empty after normalization. Such files are not compared.
+var emptyNormHash = hashBytes(nil)
+
+// DuplicateResult contains exact matches of the new work with old works.
+type DuplicateResult struct {
+	Reports []ReportItem
+	Full    map[uint64]struct{} // Old works identical to the new one.
+}
+
+// normKey identifies the normalization: its version and the default language.
+func (s *service) normKey() string {
+	return fmt.Sprintf("%d-%s", normalize.Version, s.language)
+}
+
+// getComparableFiles returns files of the work, which are not empty after normalization.
+// Normalized hashes are calculated once per blob and saved to the storage,
+// the blob is normalized as the language of the first file with it.
+func (s *service) getComparableFiles(work WorkEntry) ([]WorkFile, error) {
+	files, err := s.storage.GetWorkFiles(work.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	normKey := s.normKey()
+	result := make([]WorkFile, 0, len(files))
+	for _, file := range files {
+		if file.NormHash == "" || file.NormKey != normKey {
+			content, err := os.ReadFile(s.blobs.path(file.Hash))
+			if err != nil {
+				return nil, err
+			}
+
+			file.NormHash = hashBytes(normalize.File(file.Path, content, s.language))
+			file.NormKey = normKey
+			file.Lines = normalize.CountLines(content)
+			if err = s.storage.UpdateBlobNorm(file.Hash, file.NormHash, file.NormKey, file.Lines); err != nil {
+				s.logger.Error(err)
+			}
+		}
+
+		if file.NormHash != emptyNormHash {
+			result = append(result, file)
+		}
+	}
+
+	return result, nil
+}
+
+func sumLines(files []WorkFile) uint64 {
+	var lines uint64 = 0
+	for _, file := range files {
+		lines += file.Lines
+	}
+	return lines
+}
+
+// compareFiles reports files, which are identical after normalization, as matches of whole files.
+// Similarity is 100% for identical works, otherwise the share of matched lines of each work in percents,
+// so works sharing one file are not reported as copies.
+func compareFiles(work1 WorkEntry, files1 []WorkFile, work2 WorkEntry, files2 []WorkFile) (ReportItem, bool) {
+	report := ReportItem{
+		Work1ID: work1.Id,
+		Work2ID: work2.Id,
+		Matches: make([]MatchItem, 0),
+	}
+
+	unmatched := make(map[string][]WorkFile, len(files2))
+	for _, file := range files2 {
+		unmatched[file.NormHash] = append(unmatched[file.NormHash], file)
+	}
+
+	var lines1, lines2 uint64 = 0, 0
+	for _, file1 := range files1 {
+		candidates := unmatched[file1.NormHash]
+		if len(candidates) == 0 {
+			continue
+		}
+
+		file2 := candidates[0]
+		unmatched[file1.NormHash] = candidates[1:]
+
+		report.Matches = append(report.Matches, MatchItem{
+			Work1File:  file1.Path,
+			Work1Start: 1,
+			Work1Size:  file1.Lines,
+			Work2File:  file2.Path,
+			Work2Start: 1,
+			Work2Size:  file2.Lines,
+		})
+		lines1 += file1.Lines
+		lines2 += file2.Lines
+	}
+
+	if len(report.Matches) == 0 {
+		return report, false
+	}
+
+	full := len(report.Matches) == len(files1) && len(files1) == len(files2)
+	if full {
+		report.Avg, report.Max = 100, 100
+		return report, true
+	}
+
+	sim1 := float64(lines1) / float64(max(sumLines(files1), 1)) * 100
+	sim2 := float64(lines2) / float64(max(sumLines(files2), 1)) * 100
+	report.Avg = (sim1 + sim2) / 2
+	report.Max = max(sim1, sim2)
+
+	return report, false
+}
+
+// mergeDuplicate adds whole-file matches of the duplicate to the report of the same pair.
+// Matches of the report in the same pairs of files are replaced, similarities are the highest of both.
+func mergeDuplicate(report ReportItem, duplicate ReportItem) ReportItem {
+	swapped := report.Work1ID != duplicate.Work1ID
+	if swapped {
+		report = swapReport(report)
+	}
+
+	files := make(map[[2]string]struct{}, len(duplicate.Matches))
+	for _, match := range duplicate.Matches {
+		files[[2]string{match.Work1File, match.Work2File}] = struct{}{}
+	}
+	matches := make([]MatchItem, 0, len(report.Matches)+len(duplicate.Matches))
+	for _, match := range report.Matches {
+		if _, ok := files[[2]string{match.Work1File, match.Work2File}]; !ok {
+			matches = append(matches, match)
+		}
+	}
+	report.Matches = append(matches, duplicate.Matches...)
+	report.Avg, report.Max = max(report.Avg, duplicate.Avg), max(report.Max, duplicate.Max)
+
+	if swapped {
+		report = swapReport(report)
+	}
+	return report
+}
+
+// FindDuplicates finds old works and files, which are identical to the new ones
+// byte-for-byte or after removing whitespace and comments.
+func (s *service) FindDuplicates(newWork WorkEntry, oldWorks []WorkEntry) (DuplicateResult, error) {
+	result := DuplicateResult{
+		Reports: make([]ReportItem, 0),
+		Full:    make(map[uint64]struct{}),
+	}
+
+	newFiles, err := s.getComparableFiles(newWork)
+	if err != nil {
+		return result, err
+	}
+	if len(newFiles) == 0 {
+		return result, nil
+	}
+
+	for _, oldWork := range oldWorks {
+		oldFiles, err := s.getComparableFiles(oldWork)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
+
+		report, full := compareFiles(newWork, newFiles, oldWork, oldFiles)
+		if len(report.Matches) == 0 {
+			continue
+		}
+
+		result.Reports = append(result.Reports, report)
+		if full {
+			result.Full[oldWork.Id] = struct{}{}
+		}
+	}
+
+	return result, nil
+}
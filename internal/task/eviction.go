@@ -0,0 +1,230 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+const minStorageSize = 50 // Megabytes.
+
+var (
+	ErrTooSmallStorage  = errors.New("too small storage size")
+	ErrInvalidWatermark = errors.New("storage watermarks must satisfy 0 < low < high <= 1")
+	ErrInvalidBatchSize = errors.New("eviction batch size must be positive")
+)
+
+// EvictionPolicy describes when and which works are removed from the cache.
+type EvictionPolicy struct {
+	MaxSize        uint64        // Storage size limit in megabytes.
+	HighWatermark  float64       // Eviction starts, when the cache is fuller than this share of MaxSize.
+	LowWatermark   float64       // Eviction stops, when the cache is emptier than this share of MaxSize.
+	TTL            time.Duration // Works unused for this time are removed (0 - never).
+	BatchSize      uint64        // Count of works fetched from the storage per eviction step.
+	ActiveEventTTL time.Duration // Works of a checked event stay pinned for this time.
+}
+
+func (p EvictionPolicy) Validate() error {
+	if p.MaxSize < minStorageSize {
+		return ErrTooSmallStorage
+	}
+	if !(0 < p.LowWatermark && p.LowWatermark < p.HighWatermark && p.HighWatermark <= 1) {
+		return ErrInvalidWatermark
+	}
+	if p.BatchSize == 0 {
+		return ErrInvalidBatchSize
+	}
+	return nil
+}
+
+func (p EvictionPolicy) highBytes() uint64 {
+	return uint64(float64(p.MaxSize*1024*1024) * p.HighWatermark)
+}
+
+func (p EvictionPolicy) lowBytes() uint64 {
+	return uint64(float64(p.MaxSize*1024*1024) * p.LowWatermark)
+}
+
+type activeEvent struct {
+	works    []uint64
+	tasks    int       // Count of in-flight tasks of the event.
+	lastUsed time.Time // Time of the last release.
+}
+
+// pinRegistry keeps works, which must not be evicted:
+// works of in-flight tasks and of recently checked events.
+type pinRegistry struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	events map[uint64]*activeEvent
+}
+
+func newPinRegistry(ttl time.Duration) *pinRegistry {
+	return &pinRegistry{
+		ttl:    ttl,
+		events: make(map[uint64]*activeEvent),
+	}
+}
+
+func (r *pinRegistry) acquire(eventId uint64, works []uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok {
+		event = &activeEvent{}
+		r.events[eventId] = event
+	}
+
+	event.works = works
+	event.tasks++
+}
+
+// add pins more works of the active event.
+func (r *pinRegistry) add(eventId uint64, works []uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event, ok := r.events[eventId]; ok {
+		event.works = append(event.works, works...)
+	}
+}
+
+func (r *pinRegistry) release(eventId uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event, ok := r.events[eventId]; ok && event.tasks > 0 {
+		event.tasks--
+		event.lastUsed = time.Now()
+	}
+}
+
+// pinned returns works of active events and forgets inactive ones.
+func (r *pinRegistry) pinned() map[uint64]struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[uint64]struct{})
+	for eventId, event := range r.events {
+		if event.tasks == 0 && time.Since(event.lastUsed) >= r.ttl {
+			delete(r.events, eventId)
+			continue
+		}
+
+		for _, id := range event.works {
+			result[id] = struct{}{}
+		}
+	}
+
+	return result
+}
+
+// removeWorks removes unpinned works from the disk and the storage.
+// Returns the count of freed bytes and the count of skipped pinned works.
+func (s *service) removeWorks(works []WorkEntry, pinned map[uint64]struct{}) (removed uint64, skipped uint64, err error) {
+	ids := make([]uint64, 0, len(works))
+
+	for _, work := range works {
+		if _, ok := pinned[work.Id]; ok {
+			skipped++
+			continue
+		}
+
+		if err = os.RemoveAll(work.Path); err != nil {
+			s.logger.Error(err)
+			skipped++
+			continue
+		}
+
+		ids = append(ids, work.Id)
+	}
+
+	removed, err = s.deleteWorks(ids)
+	return removed, skipped, err
+}
+
+// deleteWorks deletes works from the storage and removes blobs, which are not used anymore.
+// Returns the count of freed bytes.
+func (s *service) deleteWorks(ids []uint64) (uint64, error) {
+	blobs, err := s.storage.DeleteWorks(ids)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed uint64 = 0
+	for _, blob := range blobs {
+		if err = s.blobs.remove(blob.Hash); err != nil {
+			s.logger.Error(err)
+		}
+		removed += blob.Size
+	}
+
+	s.used -= min(removed, s.used)
+	return removed, nil
+}
+
+// removeExpiredWorks removes works, which were not used for the TTL.
+func (s *service) removeExpiredWorks(pinned map[uint64]struct{}) error {
+	if s.policy.TTL == 0 {
+		return nil
+	}
+
+	works, err := s.storage.GetWorksUsedBefore(time.Now().Add(-s.policy.TTL))
+	if err != nil {
+		return err
+	}
+
+	removed, _, err := s.removeWorks(works, pinned)
+	if removed != 0 {
+		s.logger.Infof("Expired works evicted: %d bytes", removed)
+	}
+	return err
+}
+
+// removeOldWorks removes the least recently used works in batches
+// until the cache is below the low watermark.
+func (s *service) removeOldWorks(pinned map[uint64]struct{}) error {
+	if s.used <= s.policy.highBytes() {
+		return nil
+	}
+
+	var offset, total uint64 = 0, 0
+	for s.used > s.policy.lowBytes() {
+		works, err := s.storage.GetOldWorks(s.policy.BatchSize, offset)
+		if err != nil {
+			return err
+		}
+		if len(works) == 0 {
+			s.logger.Warn("Cache is over the low watermark, but all remaining works are pinned")
+			break
+		}
+
+		// Take only as many works from the batch as needed to reach the watermark.
+		victims := make([]WorkEntry, 0, len(works))
+		var planned uint64 = 0
+		for _, work := range works {
+			if s.used <= s.policy.lowBytes()+planned {
+				break
+			}
+			if _, ok := pinned[work.Id]; ok {
+				offset++
+				continue
+			}
+			// Shared blobs may stay, so the size of the work is only an estimate of freed bytes.
+			victims = append(victims, work)
+			planned += work.Size
+		}
+
+		removed, skipped, err := s.removeWorks(victims, pinned)
+		if err != nil {
+			return err
+		}
+		offset += skipped
+		total += removed
+	}
+
+	s.logger.Infof("Least recently used works evicted: %d bytes", total)
+	return nil
+}
@@ -0,0 +1,114 @@
+package task
+
+import (
+	"CodeBorrowing/internal/normalize"
+	"CodeBorrowing/internal/reference"
+	"errors"
+	"os"
+)
+
+var ErrInvalidExternal = errors.New("external min similarity must be in [0, 100]")
+
+// ExternalOptions configure matching of works against corpora of known public sources.
+type ExternalOptions struct {
+	Corpora       []*reference.Corpus
+	MinSimilarity float64 // Entries with the lower max similarity in percents are not reported.
+	MinLines      uint64  // Entries covering fewer lines of the work are not reported.
+}
+
+func (o ExternalOptions) Validate() error {
+	if !(0 <= o.MinSimilarity && o.MinSimilarity <= 100) {
+		return ErrInvalidExternal
+	}
+	return nil
+}
+
+// externalEntry collects matches of files of the work with the entry of the corpus.
+type externalEntry struct {
+	report  ReportItem
+	lines   uint64              // Lines of the entry.
+	covered uint64              // Lines of the work found in the entry.
+	entry   map[uint64]struct{} // Lines of the entry found in the work.
+}
+
+// FindExternal matches files of the work against corpora of known public sources.
+// Each entry of a corpus sharing enough code with the work is reported as the second work
+// of an external report, similarities are shares of covered lines in percents.
+func (s *service) FindExternal(work WorkEntry) ([]ReportItem, error) {
+	reports := make([]ReportItem, 0)
+	if len(s.external.Corpora) == 0 {
+		return reports, nil
+	}
+
+	files, err := s.storage.GetWorkFiles(work.Id)
+	if err != nil {
+		return reports, err
+	}
+
+	var workLines uint64 = 0
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		if contents[i], err = os.ReadFile(s.blobs.path(file.Hash)); err != nil {
+			return reports, err
+		}
+		workLines += normalize.CountLines(contents[i])
+	}
+
+	for _, corpus := range s.external.Corpora {
+		entries := make(map[string]*externalEntry)
+		order := make([]string, 0)
+
+		for i, file := range files {
+			for _, match := range corpus.Find(file.Path, contents[i]) {
+				entry, ok := entries[match.Entry.Name]
+				if !ok {
+					entry = &externalEntry{
+						report: ReportItem{
+							Work1ID:     work.Id,
+							Matches:     make([]MatchItem, 0),
+							Type:        ReportTypeExternal,
+							Work2Corpus: corpus.Name(),
+							Work2Name:   match.Entry.Name,
+						},
+						lines: match.Entry.Lines,
+						entry: make(map[uint64]struct{}),
+					}
+					entries[match.Entry.Name] = entry
+					order = append(order, match.Entry.Name)
+				}
+
+				entry.covered += uint64(len(match.Covered))
+				for line := range match.EntryCovered {
+					entry.entry[line] = struct{}{}
+				}
+				for _, span := range match.Spans {
+					entry.report.Matches = append(entry.report.Matches, MatchItem{
+						Work1File:  file.Path,
+						Work1Start: span.Start1,
+						Work1Size:  lineCount(span.Start1, span.End1),
+						Work2File:  match.Entry.Name,
+						Work2Start: span.Start2,
+						Work2Size:  lineCount(span.Start2, span.End2),
+					})
+				}
+			}
+		}
+
+		for _, name := range order {
+			entry := entries[name]
+			if entry.covered < s.external.MinLines {
+				continue
+			}
+
+			sim1 := float64(entry.covered) / float64(max(workLines, 1)) * 100
+			sim2 := float64(len(entry.entry)) / float64(max(entry.lines, 1)) * 100
+			entry.report.Avg = (min(sim1, 100) + min(sim2, 100)) / 2
+			entry.report.Max = min(max(sim1, sim2), 100)
+			if entry.report.Max >= s.external.MinSimilarity {
+				reports = append(reports, entry.report)
+			}
+		}
+	}
+
+	return reports, nil
+}
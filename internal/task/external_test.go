@@ -0,0 +1,91 @@
+package task
+
+import (
+	"CodeBorrowing/internal/fingerprint"
+	"CodeBorrowing/internal/reference"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const externalSearch = "static int BinarySearch(int[] sorted, int target) {\n" +
+	"    int low = 0, high = sorted.Length - 1;\n" +
+	"    while (low <= high) {\n" +
+	"        int middle = low + (high - low) / 2;\n" +
+	"        if (sorted[middle] == target) return middle;\n" +
+	"        if (sorted[middle] < target) low = middle + 1; else high = middle - 1;\n" +
+	"    }\n" +
+	"    return -1;\n" +
+	"}\n"
+
+func TestFindExternal(t *testing.T) {
+	// The entry has 28 lines, the search is on lines 20-28.
+	root := t.TempDir()
+	entry := strings.Repeat("// Chapter 5. Sorting and searching.\n", 19) + externalSearch
+	if err := os.WriteFile(filepath.Join(root, "algorithms.cs"), []byte(entry), 0644); err != nil {
+		t.Fatal(err)
+	}
+	corpus, err := reference.Load("books", root, fingerprint.Options{K: 20, Window: 1}, "csharp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The work has 18 lines, a half of them is from the entry.
+	s := newTestService(t)
+	own := "static void Main() {\n" +
+		"    var numbers = ReadNumbers();\n" +
+		"    Array.Sort(numbers);\n" +
+		"    foreach (var query in ReadQueries()) {\n" +
+		"        Console.WriteLine(BinarySearch(numbers, query));\n" +
+		"    }\n" +
+		"}\n" +
+		"\n" +
+		"// The end.\n"
+	work := saveTestWork(t, s, 1, map[string]string{"a.cs": externalSearch + own})
+
+	tests := []struct {
+		name          string
+		minLines      uint64
+		minSimilarity float64
+		reported      bool
+	}{
+		{"no thresholds", 0, 0, true},
+		{"enough lines", 9, 0, true},
+		{"too few lines", 10, 0, false},
+		{"enough similarity", 0, 50, true},
+		{"too low similarity", 0, 60, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.external = ExternalOptions{Corpora: []*reference.Corpus{corpus}, MinLines: tt.minLines, MinSimilarity: tt.minSimilarity}
+			reports, err := s.FindExternal(work)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.reported {
+				if len(reports) != 0 {
+					t.Errorf("got %+v, want no reports", reports)
+				}
+				return
+			}
+			if len(reports) != 1 {
+				t.Fatalf("got %d reports, want 1", len(reports))
+			}
+
+			report := reports[0]
+			if report.Type != ReportTypeExternal || report.Work1ID != 1 || report.Work2Corpus != "books" || report.Work2Name != "algorithms.cs" {
+				t.Errorf("got report %+v of the wrong works", report)
+			}
+			want := MatchItem{Work1File: "a.cs", Work1Start: 1, Work1Size: 9, Work2File: "algorithms.cs", Work2Start: 20, Work2Size: 9}
+			if len(report.Matches) != 1 || report.Matches[0] != want {
+				t.Errorf("got matches %+v, want %+v", report.Matches, want)
+			}
+			if avg := (50 + 900.0/28) / 2; report.Max != 50 || math.Abs(report.Avg-avg) > 1e-9 {
+				t.Errorf("got similarities %v and %v, want %v and 50", report.Avg, report.Max, avg)
+			}
+		})
+	}
+}
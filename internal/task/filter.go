@@ -0,0 +1,198 @@
+package task
+
+import (
+	"CodeBorrowing/internal/fingerprint"
+	"CodeBorrowing/internal/normalize"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrInvalidKnownCodeOverlap = errors.New("known code overlap must be in (0, 1]")
+
+// codeFilter finds lines of work files covered by fingerprints of known code.
+type codeFilter struct {
+	service      *service
+	fingerprints map[uint64]struct{}
+	files        map[uint64]map[string]string // Work -> file path -> blob hash.
+	coverage     map[string][]bool            // Blob hash -> known code lines.
+}
+
+func (f *codeFilter) workFile(workId uint64, path string) (string, bool) {
+	files, ok := f.files[workId]
+	if !ok {
+		list, err := f.service.storage.GetWorkFiles(workId)
+		if err != nil {
+			f.service.logger.Error(err)
+		}
+
+		files = make(map[string]string, len(list))
+		for _, file := range list {
+			files[file.Path] = file.Hash
+		}
+		f.files[workId] = files
+	}
+
+	hash, ok := files[path]
+	return hash, ok
+}
+
+// knownLines returns flags of lines (from 1) of the file, which belong to known code.
+func (f *codeFilter) knownLines(workId uint64, path string) []bool {
+	hash, ok := f.workFile(workId, path)
+	if !ok {
+		return nil
+	}
+	if lines, ok := f.coverage[hash]; ok {
+		return lines
+	}
+
+	content, err := os.ReadFile(f.service.blobs.path(hash))
+	if err != nil {
+		f.service.logger.Error(err)
+		return nil
+	}
+
+	code, codeLines := normalize.FileLines(path, content, f.service.language)
+	lines := make([]bool, normalize.CountLines(content)+1)
+	k := f.service.fingerprint.K
+
+	for _, fp := range fingerprint.WinnowPositions(code, f.service.fingerprint) {
+		if _, ok := f.fingerprints[fp.Hash]; !ok {
+			continue
+		}
+		for line := codeLines[fp.Pos]; line <= codeLines[fp.Pos+k-1]; line++ {
+			lines[line] = true
+		}
+	}
+
+	f.coverage[hash] = lines
+	return lines
+}
+
+// knownShare returns the share of known code lines in the span of the file.
+func (f *codeFilter) knownShare(workId uint64, path string, start uint64, size uint64) float64 {
+	lines := f.knownLines(workId, path)
+	if lines == nil || size == 0 {
+		return 0
+	}
+
+	var count uint64 = 0
+	for line := start; line < start+size && line < uint64(len(lines)); line++ {
+		if lines[line] {
+			count++
+		}
+	}
+	return float64(count) / float64(size)
+}
+
+// KnownCode is fingerprints of the base code of the event and of the ignore corpus.
+// It is collected once per task and used for all reports of the task.
+type KnownCode struct {
+	fingerprints map[uint64]struct{}
+}
+
+// GetKnownCode indexes the base code and collects fingerprints of known code.
+func (s *service) GetKnownCode(baseWorks []WorkEntry) (KnownCode, error) {
+	ids := make([]uint64, 0, len(baseWorks))
+	for _, work := range baseWorks {
+		if err := s.indexWork(work); err != nil {
+			return KnownCode{}, err
+		}
+		ids = append(ids, work.Id)
+	}
+
+	fingerprints, err := s.storage.GetWorksFingerprints(ids)
+	if err != nil {
+		return KnownCode{}, err
+	}
+
+	if s.ignore != nil {
+		for fp := range s.ignore.Fingerprints() {
+			fingerprints[fp] = struct{}{}
+		}
+	}
+	return KnownCode{fingerprints: fingerprints}, nil
+}
+
+// FilterReports removes matches, which mostly consist of the base code of the event
+// or of snippets of the ignore corpus in any of the works.
+// Similarity of a report is reduced in proportion to removed lines.
+// Reports, whose matches are all removed, are dropped.
+func (s *service) FilterReports(reports []ReportItem, known KnownCode) []ReportItem {
+	if len(reports) == 0 || len(known.fingerprints) == 0 {
+		return reports
+	}
+
+	filter := &codeFilter{
+		service:      s,
+		fingerprints: known.fingerprints,
+		files:        make(map[uint64]map[string]string),
+		coverage:     make(map[string][]bool),
+	}
+
+	result := make([]ReportItem, 0, len(reports))
+	for _, report := range reports {
+		var total, kept uint64 = 0, 0
+		matches := make([]MatchItem, 0, len(report.Matches))
+
+		for _, match := range report.Matches {
+			size := match.Work1Size + match.Work2Size
+			total += size
+
+			share1 := filter.knownShare(report.Work1ID, match.Work1File, match.Work1Start, match.Work1Size)
+			share2 := filter.knownShare(report.Work2ID, match.Work2File, match.Work2Start, match.Work2Size)
+			if max(share1, share2) >= s.knownCodeOverlap {
+				continue
+			}
+
+			matches = append(matches, match)
+			kept += size
+		}
+
+		if len(report.Matches) != 0 && len(matches) == 0 {
+			continue
+		}
+
+		if kept != total {
+			ratio := float64(kept) / float64(total)
+			report.Avg *= ratio
+			report.Max *= ratio
+		}
+		report.Matches = matches
+		result = append(result, report)
+	}
+
+	return result
+}
+
+// GetWorkSnippet returns lines of the file of the cached work.
+// Lines are counted from 1, zero size means up to the end of the file.
+func (s *service) GetWorkSnippet(workId uint64, path string, start uint64, size uint64) ([]byte, error) {
+	files, err := s.storage.GetWorkFiles(workId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.Path != path {
+			continue
+		}
+
+		content, err := os.ReadFile(s.blobs.path(file.Hash))
+		if err != nil {
+			return nil, err
+		}
+
+		lines := bytes.SplitAfter(content, []byte("\n"))
+		from := min(max(start, 1)-1, uint64(len(lines)))
+		to := uint64(len(lines))
+		if size != 0 {
+			to = min(from+size, to)
+		}
+		return bytes.Join(lines[from:to], nil), nil
+	}
+
+	return nil, fmt.Errorf("file \"%s\" of work %d is not cached", path, workId)
+}
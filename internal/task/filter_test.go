@@ -0,0 +1,93 @@
+package task
+
+import (
+	"CodeBorrowing/internal/fingerprint"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestService makes the service over a storage and blobs in a temp directory.
+func newTestService(t *testing.T) *service {
+	t.Helper()
+	blobs, err := newBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &service{
+		storage:          newTestStorage(t),
+		blobs:            blobs,
+		fingerprint:      fingerprint.Options{K: 20, Window: 8},
+		language:         "csharp",
+		knownCodeOverlap: 0.5,
+	}
+}
+
+// saveTestWork saves the work of files by paths.
+func saveTestWork(t *testing.T, s *service, id uint64, files map[string]string) WorkEntry {
+	t.Helper()
+	work := WorkEntry{Id: id, Path: "works", Timestamp: time.Now(), Format: workFormat}
+	list := make([]WorkFile, 0, len(files))
+	for path, content := range files {
+		hash, size, err := s.blobs.put(strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, WorkFile{Path: path, Hash: hash, Size: size})
+	}
+	if _, err := s.storage.SaveWork(work, list, nil); err != nil {
+		t.Fatal(err)
+	}
+	return work
+}
+
+func TestFilterReports(t *testing.T) {
+	const base = "class Skeleton {\n  void Main() {\n    Console.WriteLine(\"Enter the numbers\");\n  }\n}\n"
+	const own = "int Sum(int a, int b) {\n  return a * b + a - b;\n}\n"
+
+	s := newTestService(t)
+	baseWork := saveTestWork(t, s, 1, map[string]string{"a.cs": base})
+	saveTestWork(t, s, 2, map[string]string{"a.cs": base + own})
+	saveTestWork(t, s, 3, map[string]string{"a.cs": base + own})
+
+	known, err := s.GetKnownCode([]WorkEntry{baseWork})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseMatch := MatchItem{Work1File: "a.cs", Work1Start: 1, Work1Size: 5, Work2File: "a.cs", Work2Start: 1, Work2Size: 5}
+	ownMatch := MatchItem{Work1File: "a.cs", Work1Start: 6, Work1Size: 3, Work2File: "a.cs", Work2Start: 6, Work2Size: 3}
+
+	tests := []struct {
+		name    string
+		matches []MatchItem
+		want    []MatchItem // Nil, if the report is dropped.
+		avg     float64
+	}{
+		{"own code kept", []MatchItem{ownMatch}, []MatchItem{ownMatch}, 80},
+		{"base code removed", []MatchItem{baseMatch, ownMatch}, []MatchItem{ownMatch}, 30},
+		{"only base code", []MatchItem{baseMatch}, nil, 0},
+		{"no matches", []MatchItem{}, []MatchItem{}, 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports := s.FilterReports([]ReportItem{{Work1ID: 2, Work2ID: 3, Avg: 80, Max: 80, Matches: tt.matches}}, known)
+			if tt.want == nil {
+				if len(reports) != 0 {
+					t.Errorf("got %+v, want the report dropped", reports)
+				}
+				return
+			}
+			if len(reports) != 1 {
+				t.Fatalf("got %d reports, want 1", len(reports))
+			}
+			if len(reports[0].Matches) != len(tt.want) || len(tt.want) != 0 && reports[0].Matches[0] != tt.want[0] {
+				t.Errorf("got matches %+v, want %+v", reports[0].Matches, tt.want)
+			}
+			if reports[0].Avg != tt.avg {
+				t.Errorf("got avg %v, want %v", reports[0].Avg, tt.avg)
+			}
+		})
+	}
+}
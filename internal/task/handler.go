@@ -4,26 +4,36 @@ import (
 	"CodeBorrowing/internal/checker"
 	"CodeBorrowing/pkg/logger"
 	"errors"
-	"os"
-	"strconv"
-	"strings"
+	"sort"
 )
 
 type Handler interface {
 	Process()
 }
 
+// HandlerOptions describes how tasks are processed.
+type HandlerOptions struct {
+	SkipExact bool // Do not send exact copies of the new work to the checker.
+
+	Candidates        uint64 // Count of old works selected by fingerprints for the checker (0 - all).
+	PrefilterMinWorks uint64 // Events with fewer old works are checked without selection.
+
+	CrossLanguage bool // Compare works in other languages by abstract tokens instead of the checker.
+}
+
 type handler struct {
 	logger  *logger.Logger
 	service Service
 	checker checker.Checker
+	options HandlerOptions
 }
 
-func NewHandler(appLogger *logger.Logger, service Service, checker checker.Checker) Handler {
+func NewHandler(appLogger *logger.Logger, service Service, checker checker.Checker, options HandlerOptions) Handler {
 	return &handler{
 		logger:  appLogger,
 		service: service,
 		checker: checker,
+		options: options,
 	}
 }
 
@@ -41,46 +51,290 @@ func (h *handler) Process() {
 		h.logger.Error(err)
 		return
 	}
+	defer h.service.ReleaseEvent(task.EventID)
+	defer h.checkCacheSize()
 
-	if len(works) <= 1 {
+	if len(works) <= 1 && len(task.References) == 0 {
 		return
 	}
 
-	var newWork string
-	oldWorks := make([]string, 0, len(works))
-	idStr := strconv.FormatUint(task.WorkID, 10)
+	baseWorks, err := h.service.GetBaseCode(task.EventID)
+	if err != nil {
+		h.logger.Error(err)
+	}
+
+	known, err := h.service.GetKnownCode(baseWorks)
+	if err != nil {
+		h.logger.Error(err)
+	}
+
+	isBase := make(map[uint64]struct{}, len(baseWorks))
+	for _, work := range baseWorks {
+		isBase[work.Id] = struct{}{}
+	}
+
+	references := h.referenceWorks(task, works)
+	sink := h.tagReferences(references, h.sendReports)
+
+	if task.Type == TaskTypeSweep {
+		h.sweep(task.EventID, works, isBase, known, references, sink)
+		return
+	}
+
+	var newWork WorkEntry
+	found := false
+	oldWorks := make([]WorkEntry, 0, len(works))
 
 	for _, work := range works {
-		if strings.HasPrefix(work.Path, idStr) {
-			newWork = work.Path
-		} else {
-			oldWorks = append(oldWorks, work.Path)
+		if work.Id == task.WorkID {
+			newWork = work
+			found = true
+		} else if _, ok := isBase[work.Id]; !ok {
+			oldWorks = append(oldWorks, work)
+		}
+	}
+
+	if !found {
+		h.logger.Errorf("work %d of event %d is not available", task.WorkID, task.EventID)
+		return
+	}
+
+	for _, reference := range references {
+		oldWorks = append(oldWorks, reference.WorkEntry)
+	}
+	h.reportExternal(newWork, known, sink)
+	h.checkWork(newWork, oldWorks, known, true, sink)
+}
+
+// referenceWorks returns works of reference corpora of the task, which are not works of the event.
+func (h *handler) referenceWorks(task NewTaskDTO, works []WorkEntry) []ReferenceWork {
+	if len(task.References) == 0 {
+		return nil
+	}
+
+	references, err := h.service.GetReferenceWorks(task.EventID, task.References)
+	if err != nil {
+		h.logger.Error(err)
+	}
+
+	inEvent := make(map[uint64]struct{}, len(works))
+	for _, work := range works {
+		inEvent[work.Id] = struct{}{}
+	}
+
+	result := make([]ReferenceWork, 0, len(references))
+	for _, reference := range references {
+		if _, ok := inEvent[reference.Id]; !ok {
+			inEvent[reference.Id] = struct{}{}
+			result = append(result, reference)
 		}
 	}
+	return result
+}
 
-	resultPath, err := h.checker.Run(newWork, oldWorks)
+// tagReferences makes works of reference corpora second in reports and names their corpora.
+func (h *handler) tagReferences(references []ReferenceWork, sink func([]ReportItem)) func([]ReportItem) {
+	if len(references) == 0 {
+		return sink
+	}
+
+	byId := make(map[uint64]ReferenceWork, len(references))
+	for _, reference := range references {
+		byId[reference.Id] = reference
+	}
+
+	return func(reports []ReportItem) {
+		for i, report := range reports {
+			if _, ok := byId[report.Work1ID]; ok {
+				report = swapReport(report)
+			}
+			if reference, ok := byId[report.Work2ID]; ok {
+				report.Work2Corpus, report.Work2Name = reference.Corpus, reference.Name
+			}
+			reports[i] = report
+		}
+		sink(reports)
+	}
+}
+
+// checkWork compares the new work with old works and passes prepared reports to the sink
+// as soon as each step is done. Old works may be selected by fingerprints before the checker,
+// stored results of the checker are reused and only missing pairs are checked.
+// Exact matches are merged into reports of their pairs, so each pair is reported once.
+func (h *handler) checkWork(newWork WorkEntry, oldWorks []WorkEntry, known KnownCode, selectCandidates bool, sink func([]ReportItem)) {
+	oldWorks, duplicates := h.findDuplicates(newWork, oldWorks, known, sink)
+	report := func(reports []ReportItem) {
+		for i, item := range reports {
+			other := item.Work2ID
+			if other == newWork.Id {
+				other = item.Work1ID
+			}
+			if duplicate, ok := duplicates[other]; ok {
+				reports[i] = mergeDuplicate(item, duplicate)
+				delete(duplicates, other)
+			}
+		}
+		if len(reports) != 0 {
+			sink(h.prepareReports(reports, known))
+		}
+	}
+	// Pairs without other reports get exact matches alone.
+	defer func() {
+		rest := make([]ReportItem, 0, len(duplicates))
+		for _, duplicate := range duplicates {
+			rest = append(rest, duplicate)
+		}
+		sort.Slice(rest, func(i, j int) bool { return rest[i].Work2ID < rest[j].Work2ID })
+		report(rest)
+	}()
+
+	oldWorks = h.reportCrossLanguage(newWork, oldWorks, report)
+	if selectCandidates {
+		oldWorks = h.selectCandidates(newWork, oldWorks)
+	}
+
+	cached, err := h.service.GetCachedResults(newWork, oldWorks)
 	if err != nil {
+		h.logger.Error(err)
+		cached = CachedResults{Missing: oldWorks}
+	}
+	report(cached.Reports)
+	if len(cached.Missing) == 0 && len(oldWorks) != 0 {
+		return
+	}
+	oldWorks = cached.Missing
+
+	oldPaths := make([]string, 0, len(oldWorks))
+	for _, work := range oldWorks {
+		oldPaths = append(oldPaths, work.Path)
+	}
+
+	resultPath, err := h.checker.Run(newWork.Path, oldPaths)
+	partial := errors.Is(err, checker.ErrPartialResult)
+	if err != nil && !partial {
 		if !errors.Is(err, checker.ErrNoFiles) {
-			h.logger.Error(err)
+			h.logger.Errorf("Check of work %d failed: %v", newWork.Id, err)
 		}
 		return
 	}
-	defer os.Remove(resultPath)
 
 	result, err := h.service.ParseResults(resultPath)
+	h.checker.Release(resultPath, err != nil)
 	if err != nil {
-		h.logger.Error(err)
+		h.logger.Errorf("Results of work %d: %v", newWork.Id, err)
 		return
 	}
+	if partial {
+		h.logger.Warnf("Results of work %d are partial and are not stored", newWork.Id)
+	} else if err = h.service.SaveResults(newWork, oldWorks, result); err != nil {
+		h.logger.Error(err)
+	}
+
+	report(result)
+}
+
+// prepareReports removes matches of the base code and ignored snippets and adds details of files.
+func (h *handler) prepareReports(reports []ReportItem, known KnownCode) []ReportItem {
+	reports = h.service.FilterReports(reports, known)
+	reports = h.service.AddExcludedFiles(reports)
+	return h.service.MapNotebookCells(reports)
+}
 
-	for _, report := range result {
-		if err = h.service.SendReport(report); err != nil {
+func (h *handler) sendReports(reports []ReportItem) {
+	for _, report := range reports {
+		if err := h.service.SendReport(report); err != nil {
 			h.logger.Error(err)
 			return
 		}
 	}
+}
+
+// findDuplicates finds exact matches before running the checker. Exact copies skipped by options
+// are reported at once and are not checked. Returns old works, which still have to be checked,
+// and exact matches with them by ids of old works.
+func (h *handler) findDuplicates(newWork WorkEntry, oldWorks []WorkEntry, known KnownCode, sink func([]ReportItem)) ([]WorkEntry, map[uint64]ReportItem) {
+	pending := make(map[uint64]ReportItem)
+	duplicates, err := h.service.FindDuplicates(newWork, oldWorks)
+	if err != nil {
+		h.logger.Error(err)
+		return oldWorks, pending
+	}
+
+	skipped := make([]ReportItem, 0)
+	for _, report := range duplicates.Reports {
+		if _, full := duplicates.Full[report.Work2ID]; full && h.options.SkipExact {
+			skipped = append(skipped, report)
+		} else {
+			pending[report.Work2ID] = report
+		}
+	}
+	if len(skipped) == 0 {
+		return oldWorks, pending
+	}
+	sink(h.prepareReports(skipped, known))
+
+	rest := make([]WorkEntry, 0, len(oldWorks))
+	for _, work := range oldWorks {
+		if _, ok := duplicates.Full[work.Id]; !ok {
+			rest = append(rest, work)
+		}
+	}
+	return rest, pending
+}
+
+// reportCrossLanguage reports comparisons with works in other languages,
+// which the checker of one language can not compare. Returns old works in the language of the new one.
+func (h *handler) reportCrossLanguage(newWork WorkEntry, oldWorks []WorkEntry, sink func([]ReportItem)) []WorkEntry {
+	if !h.options.CrossLanguage {
+		return oldWorks
+	}
+
+	crossLanguage, err := h.service.FindCrossLanguage(newWork, oldWorks)
+	if err != nil {
+		h.logger.Error(err)
+		return oldWorks
+	}
+
+	sink(crossLanguage.Reports)
+
+	rest := make([]WorkEntry, 0, len(oldWorks))
+	for _, work := range oldWorks {
+		if _, ok := crossLanguage.Other[work.Id]; !ok {
+			rest = append(rest, work)
+		}
+	}
+	return rest
+}
+
+// reportExternal reports entries of corpora of known public sources found in the work.
+func (h *handler) reportExternal(work WorkEntry, known KnownCode, sink func([]ReportItem)) {
+	reports, err := h.service.FindExternal(work)
+	if err != nil {
+		h.logger.Error(err)
+		return
+	}
+	if len(reports) != 0 {
+		sink(h.prepareReports(reports, known))
+	}
+}
+
+// selectCandidates leaves old works, which are most similar to the new one by fingerprints.
+// Small events are checked completely.
+func (h *handler) selectCandidates(newWork WorkEntry, oldWorks []WorkEntry) []WorkEntry {
+	if h.options.Candidates == 0 || uint64(len(oldWorks)) < max(h.options.PrefilterMinWorks, h.options.Candidates+1) {
+		return oldWorks
+	}
+
+	candidates, err := h.service.SelectCandidates(newWork, oldWorks, h.options.Candidates)
+	if err != nil {
+		h.logger.Error(err)
+		return oldWorks
+	}
+	return candidates
+}
 
-	if err = h.service.CheckCacheSize(); err != nil {
+func (h *handler) checkCacheSize() {
+	if err := h.service.CheckCacheSize(); err != nil {
 		h.logger.Error(err)
 	}
 }
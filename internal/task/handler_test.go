@@ -0,0 +1,182 @@
+package task
+
+import (
+	"CodeBorrowing/internal/checker"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// stubService serves works and results of the checker from memory.
+// Methods, which tests do not need, are not implemented.
+type stubService struct {
+	Service
+	task       NewTaskDTO
+	works      []WorkEntry
+	base       []WorkEntry
+	duplicates DuplicateResult
+	results    map[uint64][]ReportItem // Results of the checker by the new work.
+	sent       []ReportItem
+	matrix     MatrixDTO
+}
+
+func (s *stubService) GetNewTask() (NewTaskDTO, error) {
+	return s.task, nil
+}
+
+func (s *stubService) GetEventWorks(uint64) ([]WorkEntry, error) {
+	return s.works, nil
+}
+
+func (s *stubService) GetBaseCode(uint64) ([]WorkEntry, error) {
+	return s.base, nil
+}
+
+func (s *stubService) GetKnownCode([]WorkEntry) (KnownCode, error) {
+	return KnownCode{}, nil
+}
+
+func (s *stubService) FindExternal(WorkEntry) ([]ReportItem, error) {
+	return nil, nil
+}
+
+func (s *stubService) SendReport(report ReportItem) error {
+	s.sent = append(s.sent, report)
+	return nil
+}
+
+func (s *stubService) SendMatrix(matrix MatrixDTO) error {
+	s.matrix = matrix
+	return nil
+}
+
+func (s *stubService) ReleaseEvent(uint64) {}
+
+func (s *stubService) CheckCacheSize() error {
+	return nil
+}
+
+func (s *stubService) FindDuplicates(WorkEntry, []WorkEntry) (DuplicateResult, error) {
+	return s.duplicates, nil
+}
+
+func (s *stubService) GetCachedResults(_ WorkEntry, oldWorks []WorkEntry) (CachedResults, error) {
+	return CachedResults{Missing: oldWorks}, nil
+}
+
+func (s *stubService) SaveResults(WorkEntry, []WorkEntry, []ReportItem) error {
+	return nil
+}
+
+func (s *stubService) ParseResults(path string) ([]ReportItem, error) {
+	return append([]ReportItem{}, s.results[workId(path)]...), nil
+}
+
+func (s *stubService) FilterReports(reports []ReportItem, _ KnownCode) []ReportItem {
+	return reports
+}
+
+func (s *stubService) AddExcludedFiles(reports []ReportItem) []ReportItem {
+	return reports
+}
+
+func (s *stubService) MapNotebookCells(reports []ReportItem) []ReportItem {
+	return reports
+}
+
+// stubChecker records pairs it is asked to check and returns the path of the new work as the result.
+type stubChecker struct {
+	pairs [][2]uint64
+}
+
+func (c *stubChecker) Run(newWork string, oldWorks []string) (string, error) {
+	if len(oldWorks) == 0 {
+		return "", checker.ErrNoFiles
+	}
+	for _, old := range oldWorks {
+		c.pairs = append(c.pairs, [2]uint64{workId(newWork), workId(old)})
+	}
+	return newWork, nil
+}
+
+func (c *stubChecker) Release(string, bool) {}
+
+func workId(path string) uint64 {
+	id, err := parseResultWorkId(path)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func testWork(id uint64) WorkEntry {
+	return WorkEntry{Id: id, Path: "works/" + strconv.FormatUint(id, 10)}
+}
+
+func TestCheckWorkMergesDuplicates(t *testing.T) {
+	fileMatch := MatchItem{Work1File: "a.cs", Work1Start: 1, Work1Size: 10, Work2File: "a.cs", Work2Start: 1, Work2Size: 10}
+	checkedMatch := MatchItem{Work1File: "a.cs", Work1Start: 2, Work1Size: 5, Work2File: "a.cs", Work2Start: 2, Work2Size: 5}
+	otherMatch := MatchItem{Work1File: "b.cs", Work1Start: 1, Work1Size: 3, Work2File: "c.cs", Work2Start: 4, Work2Size: 3}
+
+	tests := []struct {
+		name      string
+		skipExact bool
+		full      bool
+		results   []ReportItem
+		checked   [][2]uint64
+		want      []ReportItem
+	}{
+		{
+			name:    "partial merged into the checked report",
+			results: []ReportItem{{Work1ID: 2, Work2ID: 1, Avg: 60, Max: 70, Matches: []MatchItem{checkedMatch, otherMatch}}},
+			checked: [][2]uint64{{1, 2}},
+			want:    []ReportItem{{Work1ID: 2, Work2ID: 1, Avg: 60, Max: 70, Matches: []MatchItem{otherMatch, fileMatch}}},
+		},
+		{
+			name:    "partial without the checked report",
+			checked: [][2]uint64{{1, 2}},
+			want:    []ReportItem{{Work1ID: 1, Work2ID: 2, Avg: 40, Max: 50, Matches: []MatchItem{fileMatch}}},
+		},
+		{
+			name:    "full checked",
+			full:    true,
+			results: []ReportItem{{Work1ID: 1, Work2ID: 2, Avg: 90, Max: 95, Matches: []MatchItem{checkedMatch}}},
+			checked: [][2]uint64{{1, 2}},
+			want:    []ReportItem{{Work1ID: 1, Work2ID: 2, Avg: 100, Max: 100, Matches: []MatchItem{fileMatch}}},
+		},
+		{
+			name:      "full skipped",
+			skipExact: true,
+			full:      true,
+			want:      []ReportItem{{Work1ID: 1, Work2ID: 2, Avg: 100, Max: 100, Matches: []MatchItem{fileMatch}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duplicate := ReportItem{Work1ID: 1, Work2ID: 2, Avg: 40, Max: 50, Matches: []MatchItem{fileMatch}}
+			duplicates := DuplicateResult{Full: map[uint64]struct{}{}}
+			if tt.full {
+				duplicate.Avg, duplicate.Max = 100, 100
+				duplicates.Full[2] = struct{}{}
+			}
+			duplicates.Reports = []ReportItem{duplicate}
+
+			service := &stubService{duplicates: duplicates, results: map[uint64][]ReportItem{1: tt.results}}
+			stub := &stubChecker{}
+			h := &handler{service: service, checker: stub, options: HandlerOptions{SkipExact: tt.skipExact}}
+
+			var got []ReportItem
+			h.checkWork(testWork(1), []WorkEntry{testWork(2)}, KnownCode{}, false, func(reports []ReportItem) {
+				got = append(got, reports...)
+			})
+
+			if !reflect.DeepEqual(stub.pairs, tt.checked) {
+				t.Errorf("checked pairs %v, want %v", stub.pairs, tt.checked)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got reports %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
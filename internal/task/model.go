@@ -6,9 +6,51 @@ type WorkEntry struct {
 	Id        uint64
 	Path      string
 	Timestamp time.Time
+	Size      uint64 // Size of extracted files in bytes.
+
+	ArchiveHash string // Hash of the downloaded archive.
+	Format      uint64 // Version of the extraction, which produced files of the work.
+}
+
+// WorkFile is a file of the work stored as a blob.
+type WorkFile struct {
+	Path string // Path relative to the work directory.
+	Hash string
+	Size uint64
+
+	NormHash string // Hash of the content without comments and whitespace.
+	NormKey  string // Normalization, which made the hash.
+	Lines    uint64
+}
+
+// CandidateEntry is an old work sharing fingerprints with the new one.
+type CandidateEntry struct {
+	WorkId uint64
+	Shared uint64 // Count of shared fingerprints.
 }
 
+// BlobEntry is a unique content in the storage.
+type BlobEntry struct {
+	Hash string
+	Size uint64
+}
+
+// PairResult is the stored result of the checker for the pair of works by their contents.
+type PairResult struct {
+	Hash1  string // Content of the first work, not greater than the second one.
+	Hash2  string
+	Report string // The report in JSON, empty if the checker reported nothing.
+}
+
+// Types of reports.
+const (
+	ReportTypeWorks    = ""         // Both works are submissions.
+	ReportTypeExternal = "external" // The second work is an entry of a corpus of known public sources.
+)
+
 type ReportItem struct {
+	Type string `json:"type,omitempty"`
+
 	Work1ID uint64 `json:"work1_id"`
 	Work2ID uint64 `json:"work2_id"`
 
@@ -16,6 +58,25 @@ type ReportItem struct {
 	Max float64 `json:"max"`
 
 	Matches []MatchItem `json:"matches"`
+
+	Work1Excluded []ExcludedFile `json:"work1_excluded,omitempty"`
+	Work2Excluded []ExcludedFile `json:"work2_excluded,omitempty"`
+
+	// Works are in different languages and were compared by abstract tokens.
+	CrossLanguage bool   `json:"cross_language,omitempty"`
+	Work1Language string `json:"work1_language,omitempty"`
+	Work2Language string `json:"work2_language,omitempty"`
+
+	// The second work is from a reference corpus: a past event, an archive of old submissions
+	// or known public sources of external reports, which have no id of the second work.
+	Work2Corpus string `json:"work2_corpus,omitempty"`
+	Work2Name   string `json:"work2_name,omitempty"` // The archive or the entry of the corpus.
+}
+
+// ExcludedFile is a file of the submission, which is not compared.
+type ExcludedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
 }
 
 type ResultDTO struct {
@@ -49,19 +110,41 @@ type MatchDTO struct {
 	End2Col uint64 `json:"end2_col"`
 }
 
+// MatchItem is a pair of matched spans. Spans in notebooks start at the line of the cell.
 type MatchItem struct {
 	Work1File  string `json:"work1_file"`
+	Work1Cell  uint64 `json:"work1_cell,omitempty"` // Cell of the notebook from 1.
 	Work1Start uint64 `json:"work1_start"`
 	Work1Size  uint64 `json:"work1_size"`
 
 	Work2File  string `json:"work2_file"`
+	Work2Cell  uint64 `json:"work2_cell,omitempty"`
 	Work2Start uint64 `json:"work2_start"`
 	Work2Size  uint64 `json:"work2_size"`
 }
 
+// Types of tasks.
+const (
+	TaskTypeCheck = ""      // Compare the new work with other works of the event.
+	TaskTypeSweep = "sweep" // Compare all pairs of works of the event after its deadline.
+)
+
 type NewTaskDTO struct {
 	EventID uint64 `json:"event_id"`
-	WorkID  uint64 `json:"work_id"`
+	WorkID  uint64 `json:"work_id"` // Not used by sweeps.
+	Type    string `json:"type,omitempty"`
+
+	// Reference corpora searched as old works: "event:<id>" or "archive:<name>".
+	References []string `json:"references,omitempty"`
+}
+
+// MatrixDTO contains similarities in percents of all pairs of works of the event.
+// Rows and columns follow works, pairs without reports have zero similarity, the diagonal is 100.
+type MatrixDTO struct {
+	EventID uint64      `json:"event_id"`
+	Works   []uint64    `json:"works_id"`
+	Avg     [][]float64 `json:"avg"`
+	Max     [][]float64 `json:"max"`
 }
 
 type WorksIdDTO struct {
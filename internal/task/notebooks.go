@@ -0,0 +1,77 @@
+package task
+
+import (
+	"CodeBorrowing/internal/notebook"
+	"os"
+)
+
+// notebookSources reads sources of notebooks of works once per call.
+type notebookSources struct {
+	service *service
+	files   map[uint64]map[string]string // Work -> path -> blob hash.
+	sources map[string][]byte            // Blob hash -> content.
+}
+
+func (n *notebookSources) get(workId uint64, path string) ([]byte, bool) {
+	files, ok := n.files[workId]
+	if !ok {
+		workFiles, err := n.service.storage.GetWorkFiles(workId)
+		if err != nil {
+			n.service.logger.Error(err)
+		}
+		files = make(map[string]string, len(workFiles))
+		for _, file := range workFiles {
+			files[file.Path] = file.Hash
+		}
+		n.files[workId] = files
+	}
+
+	hash, ok := files[path]
+	if !ok {
+		return nil, false
+	}
+	if content, ok := n.sources[hash]; ok {
+		return content, true
+	}
+
+	content, err := os.ReadFile(n.service.blobs.path(hash))
+	if err != nil {
+		n.service.logger.Error(err)
+		return nil, false
+	}
+	n.sources[hash] = content
+	return content, true
+}
+
+// locate replaces the position in the source of a notebook with the position in its cell.
+func (n *notebookSources) locate(workId uint64, file *string, cell *uint64, start *uint64) {
+	name, ok := notebook.NotebookName(*file)
+	if !ok {
+		return
+	}
+	source, ok := n.get(workId, *file)
+	if !ok {
+		return
+	}
+	position, ok := notebook.Locate(source, *start)
+	if !ok {
+		return
+	}
+	*file, *cell, *start = name, position.Cell, position.Line
+}
+
+// MapNotebookCells makes matches in sources of notebooks refer to cells of the notebooks.
+// Sizes of matches are kept, so a match may continue in the next cells.
+func (s *service) MapNotebookCells(reports []ReportItem) []ReportItem {
+	sources := &notebookSources{service: s, files: make(map[uint64]map[string]string), sources: make(map[string][]byte)}
+
+	for i := range reports {
+		report := &reports[i]
+		for j := range report.Matches {
+			match := &report.Matches[j]
+			sources.locate(report.Work1ID, &match.Work1File, &match.Work1Cell, &match.Work1Start)
+			sources.locate(report.Work2ID, &match.Work2File, &match.Work2Cell, &match.Work2Start)
+		}
+	}
+	return reports
+}
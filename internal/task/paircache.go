@@ -0,0 +1,157 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// CachedResults split old works into ones with stored results of the checker and missing ones.
+type CachedResults struct {
+	Reports []ReportItem
+	Missing []WorkEntry // Old works, which still have to be checked.
+}
+
+// contentHash identifies files of the work: their paths and contents.
+func (s *service) contentHash(work WorkEntry) (string, error) {
+	files, err := s.storage.GetWorkFiles(work.Id)
+	if err != nil {
+		return "", err
+	}
+	return workContentHash(files), nil
+}
+
+// workContentHash hashes paths and contents of files ordered by paths.
+func workContentHash(files []WorkFile) string {
+	var buf bytes.Buffer
+	for _, file := range files {
+		buf.WriteString(file.Path)
+		buf.WriteByte(0)
+		buf.WriteString(file.Hash)
+		buf.WriteByte('\n')
+	}
+	return hashBytes(buf.Bytes())
+}
+
+// swapReport returns the report with the works in the other order.
+func swapReport(report ReportItem) ReportItem {
+	report.Work1ID, report.Work2ID = report.Work2ID, report.Work1ID
+	report.Work1Excluded, report.Work2Excluded = report.Work2Excluded, report.Work1Excluded
+	report.Work1Language, report.Work2Language = report.Work2Language, report.Work1Language
+
+	matches := make([]MatchItem, len(report.Matches))
+	for i, m := range report.Matches {
+		matches[i] = MatchItem{
+			Work1File: m.Work2File, Work1Cell: m.Work2Cell, Work1Start: m.Work2Start, Work1Size: m.Work2Size,
+			Work2File: m.Work1File, Work2Cell: m.Work1Cell, Work2Start: m.Work1Start, Work2Size: m.Work1Size,
+		}
+	}
+	report.Matches = matches
+	return report
+}
+
+// GetCachedResults returns stored results of the checker for pairs of the new work with old works.
+// Results are stored by contents of works and the result key of the checker,
+// so changes of files, of the checker or its settings make them missing.
+func (s *service) GetCachedResults(newWork WorkEntry, oldWorks []WorkEntry) (CachedResults, error) {
+	result := CachedResults{Reports: make([]ReportItem, 0)}
+	if s.resultKey == "" {
+		result.Missing = oldWorks
+		return result, nil
+	}
+
+	newHash, err := s.contentHash(newWork)
+	if err != nil {
+		return result, err
+	}
+
+	for _, oldWork := range oldWorks {
+		oldHash, err := s.contentHash(oldWork)
+		if err != nil {
+			s.logger.Error(err)
+			result.Missing = append(result.Missing, oldWork)
+			continue
+		}
+
+		swapped := oldHash < newHash
+		hash1, hash2 := newHash, oldHash
+		if swapped {
+			hash1, hash2 = oldHash, newHash
+		}
+
+		data, ok, err := s.storage.GetPairResult(hash1, hash2, s.resultKey)
+		if err != nil {
+			s.logger.Error(err)
+		}
+		if !ok {
+			result.Missing = append(result.Missing, oldWork)
+			continue
+		}
+		if data == "" {
+			continue
+		}
+
+		var report ReportItem
+		if err = json.Unmarshal([]byte(data), &report); err != nil {
+			s.logger.Error(err)
+			result.Missing = append(result.Missing, oldWork)
+			continue
+		}
+		if swapped {
+			report = swapReport(report)
+		}
+		report.Work1ID, report.Work2ID = newWork.Id, oldWork.Id
+		result.Reports = append(result.Reports, report)
+	}
+
+	return result, nil
+}
+
+// SaveResults stores results of the checker for pairs of the new work with old works.
+// Pairs without reports are stored too, so they are not checked again.
+func (s *service) SaveResults(newWork WorkEntry, oldWorks []WorkEntry, reports []ReportItem) error {
+	if s.resultKey == "" {
+		return nil
+	}
+
+	newHash, err := s.contentHash(newWork)
+	if err != nil {
+		return err
+	}
+
+	byWork := make(map[uint64]ReportItem, len(reports))
+	for _, report := range reports {
+		if report.Work2ID == newWork.Id {
+			report = swapReport(report)
+		}
+		if report.Work1ID == newWork.Id {
+			byWork[report.Work2ID] = report
+		}
+	}
+
+	results := make([]PairResult, 0, len(oldWorks))
+	for _, oldWork := range oldWorks {
+		oldHash, err := s.contentHash(oldWork)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
+
+		pair := PairResult{Hash1: newHash, Hash2: oldHash}
+		if report, ok := byWork[oldWork.Id]; ok {
+			if oldHash < newHash {
+				report = swapReport(report)
+			}
+			data, err := json.Marshal(report)
+			if err != nil {
+				return err
+			}
+			pair.Report = string(data)
+		}
+		if oldHash < newHash {
+			pair.Hash1, pair.Hash2 = oldHash, newHash
+		}
+		results = append(results, pair)
+	}
+
+	return s.storage.SavePairResults(s.resultKey, results)
+}
@@ -0,0 +1,196 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of reference corpora named by tasks as "kind:name".
+const (
+	ReferenceEvent   = "event"   // Works of a past event by its id.
+	ReferenceArchive = "archive" // Archives of old submissions in a local directory by its name.
+)
+
+// archiveIdBit marks ids of works imported from local archives, so they do not clash with ids of the server.
+const archiveIdBit = 1 << 62
+
+var ErrUnknownReference = errors.New("unknown reference corpus")
+
+// ReferenceWork is an old work of a reference corpus.
+type ReferenceWork struct {
+	WorkEntry
+	Corpus string // The corpus as named by the task.
+	Name   string // Path of the archive inside the directory of the archive corpus.
+}
+
+// archiveFile is an imported archive of the archive corpus.
+type archiveFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// archiveIndex remembers imported archives, so unchanged ones are not read again.
+type archiveIndex struct {
+	mu    sync.Mutex
+	files map[uint64]archiveFile // Work -> its archive at the time of the import.
+}
+
+// archiveWorkId returns the id of the work imported from the archive of the corpus.
+func archiveWorkId(corpus string, name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(corpus + "/" + name))
+	return archiveIdBit | h.Sum64()&(archiveIdBit-1)
+}
+
+// GetReferenceWorks returns works of reference corpora named by the task of the event.
+// Works are cached and indexed as other works and pinned with works of the event.
+// Unknown and broken corpora are skipped.
+func (s *service) GetReferenceWorks(eventId uint64, references []string) ([]ReferenceWork, error) {
+	result := make([]ReferenceWork, 0)
+	var errs []error
+
+	for _, reference := range references {
+		kind, name, _ := strings.Cut(reference, ":")
+
+		var works []ReferenceWork
+		var err error
+		switch kind {
+		case ReferenceEvent:
+			works, err = s.getEventReference(eventId, reference, name)
+		case ReferenceArchive:
+			works, err = s.getArchiveReference(eventId, reference, name)
+		default:
+			err = fmt.Errorf("%w: \"%s\"", ErrUnknownReference, reference)
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+		result = append(result, works...)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// getEventReference returns works of the past event.
+func (s *service) getEventReference(eventId uint64, reference string, name string) ([]ReferenceWork, error) {
+	pastId, err := strconv.ParseUint(name, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: \"%s\"", ErrUnknownReference, reference)
+	}
+	if pastId == eventId {
+		return nil, nil
+	}
+
+	ids, err := s.getWorksId(pastId)
+	if err != nil {
+		return nil, err
+	}
+	s.pins.add(eventId, ids)
+
+	works, notFound := s.getWorksEntry(ids)
+	downloaded, err := s.downloadWorks(notFound)
+	works = append(works, downloaded...)
+
+	result := make([]ReferenceWork, 0, len(works))
+	for _, work := range works {
+		result = append(result, ReferenceWork{WorkEntry: work, Corpus: reference})
+	}
+	return result, err
+}
+
+// getArchiveReference returns works of zip archives in the directory of the archive corpus.
+// Archives are imported on the first use and again after they change.
+func (s *service) getArchiveReference(eventId uint64, reference string, name string) ([]ReferenceWork, error) {
+	root, ok := s.referenceArchives[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: \"%s\"", ErrUnknownReference, reference)
+	}
+
+	result := make([]ReferenceWork, 0)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".zip") {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(root, path)
+		relPath = filepath.ToSlash(relPath)
+
+		id := archiveWorkId(name, relPath)
+		s.pins.add(eventId, []uint64{id})
+
+		work, err := s.importArchive(id, path, info)
+		if err != nil {
+			s.logger.Errorf("Archive \"%s\" of corpus \"%s\": %v", relPath, reference, err)
+			return nil
+		}
+		result = append(result, ReferenceWork{WorkEntry: work, Corpus: reference, Name: relPath})
+		return nil
+	})
+
+	return result, err
+}
+
+// importArchive returns the work of the local archive, which is extracted, if it is not cached or changed.
+func (s *service) importArchive(id uint64, path string, info fs.FileInfo) (WorkEntry, error) {
+	s.archives.mu.Lock()
+	imported, ok := s.archives.files[id]
+	s.archives.mu.Unlock()
+
+	if ok && imported.size == info.Size() && imported.modTime.Equal(info.ModTime()) {
+		if works, notFound := s.getWorksEntry([]uint64{id}); len(notFound) == 0 {
+			return works[0], nil
+		}
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return WorkEntry{}, err
+	}
+
+	// The work may be cached by the previous run of the worker.
+	// The work of the changed archive is removed, so files deleted from the archive are forgotten.
+	if works, notFound := s.getWorksEntry([]uint64{id}); len(notFound) == 0 {
+		if works[0].ArchiveHash == hashBytes(buf) {
+			s.rememberArchive(id, info)
+			return works[0], nil
+		}
+
+		_, skipped, err := s.removeWorks(works, nil)
+		if err != nil {
+			return works[0], err
+		}
+		if skipped != 0 {
+			return works[0], fmt.Errorf("outdated work %d of the archive is not removed", id)
+		}
+	}
+
+	work, err := s.saveArchive(id, buf)
+	if err != nil {
+		return work, err
+	}
+	s.rememberArchive(id, info)
+	return work, nil
+}
+
+func (s *service) rememberArchive(id uint64, info fs.FileInfo) {
+	s.archives.mu.Lock()
+	defer s.archives.mu.Unlock()
+
+	s.archives.files[id] = archiveFile{size: info.Size(), modTime: info.ModTime()}
+}
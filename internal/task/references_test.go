@@ -0,0 +1,193 @@
+package task
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newReferenceService makes the test service, which imports archives of the corpus in the directory.
+func newReferenceService(t *testing.T, corpus string, dir string) *service {
+	t.Helper()
+	appLogger := &logger.Logger{Logger: *logrus.New()}
+	appLogger.SetOutput(io.Discard)
+
+	s := newTestService(t)
+	s.logger = appLogger
+	s.root = t.TempDir()
+	s.pins = newPinRegistry(time.Hour)
+	s.archives = &archiveIndex{files: make(map[uint64]archiveFile)}
+	s.referenceArchives = map[string]string{corpus: dir}
+	return s
+}
+
+// writeTestArchive writes the zip archive of files by paths.
+func writeTestArchive(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	writer := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// workFilePaths returns sorted paths of files of the stored work.
+func workFilePaths(t *testing.T, s *service, id uint64) []string {
+	t.Helper()
+	files, err := s.storage.GetWorkFiles(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestArchiveWorkId(t *testing.T) {
+	id := archiveWorkId("2023", "group1/alice.zip")
+	if id != archiveWorkId("2023", "group1/alice.zip") {
+		t.Error("id of the same archive changed")
+	}
+	if id&archiveIdBit == 0 {
+		t.Errorf("id %x has no archive bit", id)
+	}
+
+	for _, other := range []uint64{
+		archiveWorkId("2023", "group1/bob.zip"),
+		archiveWorkId("2022", "group1/alice.zip"),
+	} {
+		if other == id {
+			t.Errorf("id %x of other archive is the same", other)
+		}
+	}
+}
+
+func TestGetReferenceWorksUnknown(t *testing.T) {
+	s := newReferenceService(t, "2023", t.TempDir())
+
+	tests := []string{"foo:1", "archive:2022", "event:last", "2023"}
+	for _, reference := range tests {
+		t.Run(reference, func(t *testing.T) {
+			works, err := s.GetReferenceWorks(5, []string{reference})
+			if !errors.Is(err, ErrUnknownReference) {
+				t.Errorf("got error %v, want %v", err, ErrUnknownReference)
+			}
+			if len(works) != 0 {
+				t.Errorf("got %d works, want none", len(works))
+			}
+		})
+	}
+}
+
+func TestGetReferenceWorksArchive(t *testing.T) {
+	dir := t.TempDir()
+	writeTestArchive(t, filepath.Join(dir, "group1", "alice.zip"), map[string]string{"a.cs": "class A {}\n"})
+	writeTestArchive(t, filepath.Join(dir, "bob.ZIP"), map[string]string{"b.cs": "class B {}\n"})
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newReferenceService(t, "2023", dir)
+
+	// The unknown corpus is reported, but works of known ones are still returned.
+	works, err := s.GetReferenceWorks(5, []string{"archive:2023", "foo:x"})
+	if !errors.Is(err, ErrUnknownReference) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownReference)
+	}
+
+	got := make(map[string]uint64)
+	for _, work := range works {
+		if work.Corpus != "archive:2023" {
+			t.Errorf("work %q of corpus %q, want \"archive:2023\"", work.Name, work.Corpus)
+		}
+		got[work.Name] = work.Id
+	}
+	want := map[string]uint64{
+		"group1/alice.zip": archiveWorkId("2023", "group1/alice.zip"),
+		"bob.ZIP":          archiveWorkId("2023", "bob.ZIP"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got works %v, want %v", got, want)
+	}
+	for name, id := range want {
+		if got[name] != id {
+			t.Errorf("work %q has id %d, want %d", name, got[name], id)
+		}
+	}
+
+	if paths := workFilePaths(t, s, want["group1/alice.zip"]); len(paths) != 1 || paths[0] != "a.cs" {
+		t.Errorf("got files %v, want [a.cs]", paths)
+	}
+}
+
+func TestImportChangedArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alice.zip")
+	writeTestArchive(t, path, map[string]string{"a.cs": "class A {}\n", "old.cs": "class Old {}\n"})
+
+	s := newReferenceService(t, "2023", dir)
+	id := archiveWorkId("2023", "alice.zip")
+
+	if _, err := s.GetReferenceWorks(5, []string{"archive:2023"}); err != nil {
+		t.Fatal(err)
+	}
+	if paths := workFilePaths(t, s, id); len(paths) != 2 {
+		t.Fatalf("got files %v, want [a.cs old.cs]", paths)
+	}
+
+	// The changed archive is imported again and the deleted file is forgotten.
+	writeTestArchive(t, path, map[string]string{"a.cs": "class A { int x; }\n", "new.cs": "class New {}\n"})
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	works, err := s.GetReferenceWorks(5, []string{"archive:2023"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(works) != 1 || works[0].Id != id {
+		t.Fatalf("got works %+v, want work %d", works, id)
+	}
+
+	paths := workFilePaths(t, s, id)
+	if len(paths) != 2 || paths[0] != "a.cs" || paths[1] != "new.cs" {
+		t.Errorf("got files %v, want [a.cs new.cs]", paths)
+	}
+	content, err := os.ReadFile(filepath.Join(works[0].Path, strconv.FormatUint(id, 10), "a.cs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "class A { int x; }\n" {
+		t.Errorf("got content %q of the changed file", content)
+	}
+}
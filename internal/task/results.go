@@ -0,0 +1,91 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ParseResults reads comparisons of works written by the checker.
+// Similarities are converted to percents, files of matches to paths inside works.
+func (s *service) ParseResults(resultPath string) ([]ReportItem, error) {
+	data, err := os.ReadFile(resultPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ResultDTO
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	reports := make([]ReportItem, 0, len(results))
+	for _, result := range results {
+		id1, err1 := parseResultWorkId(result.ID1)
+		id2, err2 := parseResultWorkId(result.ID2)
+		if err1 != nil || err2 != nil {
+			s.logger.Warnf("Comparison of unknown works \"%s\" and \"%s\" is skipped", result.ID1, result.ID2)
+			continue
+		}
+
+		report := ReportItem{
+			Work1ID: id1,
+			Work2ID: id2,
+			Avg:     result.Similarities.Avg * 100,
+			Max:     result.Similarities.Max * 100,
+			Matches: make([]MatchItem, 0, len(result.Matches)),
+		}
+		for _, match := range result.Matches {
+			report.Matches = append(report.Matches, MatchItem{
+				Work1File:  resultFilePath(id1, match.File1),
+				Work1Start: match.Start1,
+				Work1Size:  lineCount(match.Start1, match.End1),
+				Work2File:  resultFilePath(id2, match.File2),
+				Work2Start: match.Start2,
+				Work2Size:  lineCount(match.Start2, match.End2),
+			})
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// parseResultWorkId returns the id of the work by the name or the path of its directory.
+func parseResultWorkId(id string) (uint64, error) {
+	name := path.Base(strings.ReplaceAll(id, "\\", "/"))
+	workId, err := strconv.ParseUint(name, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("work id \"%s\": %w", id, err)
+	}
+	return workId, nil
+}
+
+// resultFilePath returns the path of the file relative to the directory of the archive.
+// Checkers give paths with the name of the work and the directory of the archive, which are both the id.
+func resultFilePath(workId uint64, file string) string {
+	file = strings.ReplaceAll(file, "\\", "/")
+	prefix := strconv.FormatUint(workId, 10) + "/"
+
+	if path.IsAbs(file) {
+		if i := strings.Index(file, "/"+prefix+prefix); i >= 0 {
+			file = file[i+1:]
+		} else if i = strings.LastIndex(file, "/"+prefix); i >= 0 {
+			file = file[i+1:]
+		}
+	}
+	for n := 0; n < 2 && strings.HasPrefix(file, prefix); n++ {
+		file = strings.TrimPrefix(file, prefix)
+	}
+	return file
+}
+
+func lineCount(start uint64, end uint64) uint64 {
+	if end < start {
+		return 1
+	}
+	return end - start + 1
+}
@@ -0,0 +1,28 @@
+package task
+
+import "testing"
+
+func TestResultFilePath(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"work and archive", "7/7/a.cs", "a.cs"},
+		{"work only", "7/a.cs", "a.cs"},
+		{"directory of the work kept", "7/7/7/a.cs", "7/a.cs"},
+		{"relative directory kept", "src/7/a.cs", "src/7/a.cs"},
+		{"absolute path", "/data/works/7/7/src/a.cs", "src/a.cs"},
+		{"absolute path of the work", "/data/works/7/a.cs", "a.cs"},
+		{"absolute path of another work", "/data/17/7/a.cs", "a.cs"},
+		{"backslashes", "7\\7\\src\\a.cs", "src/a.cs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resultFilePath(7, tt.file); got != tt.want {
+				t.Errorf("resultFilePath(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
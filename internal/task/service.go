@@ -1,19 +1,31 @@
 package task
 
 import (
+	"CodeBorrowing/internal/charset"
+	"CodeBorrowing/internal/fingerprint"
+	"CodeBorrowing/internal/ignore"
+	"CodeBorrowing/internal/notebook"
 	"CodeBorrowing/internal/router"
+	"CodeBorrowing/internal/submission"
+	"CodeBorrowing/internal/tokenize"
 	"CodeBorrowing/internal/utils"
 	"CodeBorrowing/pkg/logger"
 	"archive/zip"
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -21,40 +33,146 @@ const (
 	urlGetAllWorks = "/api/works"
 	urlGetWorksUrl = "/api/worksurl"
 	urlPostReport  = "/api/crossreport"
+	urlPostMatrix  = "/api/matrix"
 )
 
+const zipUnicodePathTag = 0x7075
+
+// workFormat is the version of the extraction: filtering and conversion of files.
+// Works extracted by older versions are extracted again.
+const workFormat = 2
+
+// Notebooks keep outputs and images, so they may be much larger than their code.
+const notebookSizeFactor = 16
+
 var NoNewTaskErr = errors.New("no new task")
+var ErrUnknownLanguage = errors.New("unknown language")
 
 type Service interface {
 	GetNewTask() (NewTaskDTO, error)
 	GetEventWorks(eventId uint64) ([]WorkEntry, error)
+	GetBaseCode(eventId uint64) ([]WorkEntry, error)
+	GetReferenceWorks(eventId uint64, references []string) ([]ReferenceWork, error)
+	FindDuplicates(newWork WorkEntry, oldWorks []WorkEntry) (DuplicateResult, error)
+	FindCrossLanguage(newWork WorkEntry, oldWorks []WorkEntry) (CrossLanguageResult, error)
+	FindExternal(work WorkEntry) ([]ReportItem, error)
+	SelectCandidates(newWork WorkEntry, oldWorks []WorkEntry, count uint64) ([]WorkEntry, error)
+	GetCachedResults(newWork WorkEntry, oldWorks []WorkEntry) (CachedResults, error)
+	SaveResults(newWork WorkEntry, oldWorks []WorkEntry, reports []ReportItem) error
 	ParseResults(path string) ([]ReportItem, error)
+	GetKnownCode(baseWorks []WorkEntry) (KnownCode, error)
+	FilterReports(reports []ReportItem, known KnownCode) []ReportItem
+	GetWorkSnippet(workId uint64, path string, start uint64, size uint64) ([]byte, error)
+	AddExcludedFiles(reports []ReportItem) []ReportItem
+	MapNotebookCells(reports []ReportItem) []ReportItem
 	SendReport(report ReportItem) error
+	SendMatrix(matrix MatrixDTO) error
+	ReleaseEvent(eventId uint64)
 	CheckCacheSize() error
 }
 
+// ServiceOptions describes the cache of works.
+type ServiceOptions struct {
+	Path              string
+	Eviction          EvictionPolicy
+	ReconcileInterval time.Duration // How often the total size is checked against the disk.
+	Fingerprint       fingerprint.Options
+	Ignore            ignore.Corpus
+	Rules             submission.Rules // Which files of submissions are extracted.
+	Language          string           // Language of files with unknown extensions.
+	CrossLanguage     CrossLanguageOptions
+	KnownCodeOverlap  float64           // Matches with this share of base code or ignored lines are removed.
+	ResultKey         string            // Identifies the checker and its settings for stored results ("" - not stored).
+	ReferenceArchives map[string]string // Directories of archive corpora by their names.
+	External          ExternalOptions
+}
+
 type service struct {
-	storage Storage
-	logger  *logger.Logger
-	root    string
-	size    uint64
+	storage     Storage
+	logger      *logger.Logger
+	root        string
+	policy      EvictionPolicy
+	pins        *pinRegistry
+	blobs       *blobStore
+	fingerprint fingerprint.Options
+
+	ignore           ignore.Corpus
+	rules            submission.Rules
+	language         string
+	crossLanguage    CrossLanguageOptions
+	external         ExternalOptions
+	knownCodeOverlap float64
+	resultKey        string
+
+	referenceArchives map[string]string
+	archives          *archiveIndex
+
+	used              uint64        // Running total of works size in bytes.
+	reconcileInterval time.Duration // How often the total is checked against the disk.
+	lastReconcile     time.Time
 }
 
-func NewService(taskStorage Storage, logger *logger.Logger, path string, size uint64) (Service, error) {
-	_, err := utils.CreateDirectory(path)
+func NewService(taskStorage Storage, logger *logger.Logger, options ServiceOptions) (Service, error) {
+	if err := options.Eviction.Validate(); err != nil {
+		return nil, err
+	}
+	if err := options.Fingerprint.Validate(); err != nil {
+		return nil, err
+	}
+	if !(0 < options.KnownCodeOverlap && options.KnownCodeOverlap <= 1) {
+		return nil, ErrInvalidKnownCodeOverlap
+	}
+	if err := options.CrossLanguage.Validate(); err != nil {
+		return nil, err
+	}
+	if err := options.External.Validate(); err != nil {
+		return nil, err
+	}
+	if _, ok := tokenize.ForLanguage(options.Language); !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLanguage, options.Language)
+	}
+
+	_, err := utils.CreateDirectory(options.Path)
 	if err != nil {
 		return nil, err
 	}
 
-	if size < 50 {
-		return nil, errors.New("too small storage size")
+	blobs, err := newBlobStore(fmt.Sprintf("%s/blobs", options.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	// Results of other checkers or settings are not used anymore.
+	if options.ResultKey != "" {
+		if err = taskStorage.DeletePairResults(options.ResultKey); err != nil {
+			return nil, err
+		}
+	}
+
+	used, err := taskStorage.GetTotalSize()
+	if err != nil {
+		return nil, err
 	}
 
 	return &service{
-		storage: taskStorage,
-		logger:  logger,
-		root:    path,
-		size:    size,
+		storage:           taskStorage,
+		logger:            logger,
+		root:              options.Path,
+		policy:            options.Eviction,
+		pins:              newPinRegistry(options.Eviction.ActiveEventTTL),
+		blobs:             blobs,
+		fingerprint:       options.Fingerprint,
+		ignore:            options.Ignore,
+		rules:             options.Rules,
+		language:          options.Language,
+		crossLanguage:     options.CrossLanguage,
+		external:          options.External,
+		knownCodeOverlap:  options.KnownCodeOverlap,
+		resultKey:         options.ResultKey,
+		referenceArchives: options.ReferenceArchives,
+		archives:          &archiveIndex{files: make(map[uint64]archiveFile)},
+		used:              used,
+		reconcileInterval: options.ReconcileInterval,
 	}, nil
 }
 
@@ -84,8 +202,12 @@ func makeGetDownloadUrlsRequest(ids []uint64) (*http.Response, error) {
 	return client.Do(req)
 }
 
+func (s *service) getWorksPath() string {
+	return fmt.Sprintf("%s/works", s.root)
+}
+
 func (s *service) getWorkPath(workID uint64) string {
-	return fmt.Sprintf("%s/works/%d", s.root, workID)
+	return fmt.Sprintf("%s/%d", s.getWorksPath(), workID)
 }
 
 func (s *service) GetNewTask() (NewTaskDTO, error) {
@@ -151,9 +273,20 @@ func (s *service) getWorksEntry(ids []uint64) (works []WorkEntry, notFound []uin
 		work, err := s.storage.GetWork(id)
 		if err != nil {
 			notFound = append(notFound, id)
-		} else {
-			works = append(works, work)
+			continue
 		}
+
+		// The outdated work is used as is, if it can not be removed.
+		if work.Format != workFormat {
+			_, skipped, err := s.removeWorks([]WorkEntry{work}, nil)
+			if err == nil && skipped == 0 {
+				notFound = append(notFound, id)
+				continue
+			}
+			s.logger.Errorf("Outdated work %d is not removed: %v", id, err)
+		}
+
+		works = append(works, work)
 	}
 
 	if err := s.storage.UpdateWorksTimestamp(ids, time.Now()); err != nil {
@@ -236,56 +369,216 @@ func downloadFile(url string) ([]byte, error) {
 	}
 	defer res.Body.Close()
 
-	buf := bytes.NewBuffer(make([]byte, res.ContentLength))
-	_, err = io.Copy(buf, req.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", url, res.Status)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+// extractPath returns the path of the archive entry inside the directory
+// or an error, if the entry points outside of it.
+func extractPath(dir string, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	if path != dir && !strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal file path in archive: \"%s\"", name)
+	}
+	return path, nil
+}
+
+// zipFileName returns the name of the archive entry in UTF-8 with "/" separators.
+// The Info-ZIP Unicode path is preferred, legacy names are decoded.
+func zipFileName(f *zip.File) string {
+	name := f.Name
+	if unicodePath, ok := zipUnicodePath(f); ok {
+		name = unicodePath
+	} else if f.NonUTF8 {
+		name = charset.Name(name)
+	}
+	return strings.ReplaceAll(name, "\\", "/")
+}
+
+// zipUnicodePath reads the Info-ZIP Unicode path extra field, which is valid
+// only while the checksum matches the original name.
+func zipUnicodePath(f *zip.File) (string, bool) {
+	extra := f.Extra
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			break
+		}
+		field := extra[4 : 4+size]
+		extra = extra[4+size:]
+
+		if tag != zipUnicodePathTag || len(field) < 5 || field[0] != 1 {
+			continue
+		}
+		if crc32.ChecksumIEEE([]byte(f.Name)) != binary.LittleEndian.Uint32(field[1:5]) {
+			continue
+		}
+		if name := string(field[5:]); utf8.ValidString(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// readZipFile reads the file of the archive, if it passes the rules.
+// Notebooks are replaced by sources of their code cells, which are checked by the rules
+// with their own names. Returns the content with its name or the reason of exclusion.
+func (s *service) readZipFile(f *zip.File, name string) ([]byte, string, string, error) {
+	isNotebook := notebook.IsNotebook(name)
+	reason := s.rules.CheckPath(name)
+	if reason != "" && !(isNotebook && reason == submission.ReasonNotIncluded) {
+		return nil, name, reason, nil
+	}
+
+	maxSize := s.rules.MaxFileSize
+	if isNotebook {
+		maxSize *= notebookSizeFactor
+	}
+	if maxSize != 0 && f.UncompressedSize64 > maxSize {
+		return nil, name, submission.ReasonTooLarge, nil
+	}
+
+	rc, err := f.Open()
 	if err != nil {
-		return nil, err
+		return nil, name, "", err
 	}
+	defer rc.Close()
+
+	// The size in the header may lie, so reading is limited too.
+	reader := io.Reader(rc)
+	if maxSize != 0 {
+		reader = io.LimitReader(rc, int64(maxSize)+1)
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, name, "", err
+	}
+
+	if isNotebook {
+		nb, err := notebook.Extract(content)
+		if err != nil {
+			return nil, name, submission.ReasonBadNotebook, nil
+		}
+		content = nb.Source
+		name = notebook.SourceName(name, nb.Language)
 
-	return buf.Bytes(), nil
+		if reason != "" {
+			if reason = s.rules.CheckPath(name); reason != "" {
+				return nil, name, reason, nil
+			}
+		}
+		if s.rules.MaxFileSize != 0 && uint64(len(content)) > s.rules.MaxFileSize {
+			return nil, name, submission.ReasonTooLarge, nil
+		}
+	}
+
+	return content, name, s.rules.CheckContent(content), nil
 }
 
-func (s *service) unzipWork(path string, buf []byte) error {
+// unzipWork extracts files of the archive, which pass the rules, to the blob store
+// and links them to the directory. Returns extracted and excluded files.
+func (s *service) unzipWork(path string, buf []byte) ([]WorkFile, []ExcludedFile, error) {
 	reader := bytes.NewReader(buf)
 	zipReader, err := zip.NewReader(reader, int64(len(buf)))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
+	path = filepath.Clean(path)
+	files := make([]WorkFile, 0, len(zipReader.File))
+	excluded := make([]ExcludedFile, 0)
+
 	for _, f := range zipReader.File {
-		rc, err := f.Open()
+		name := zipFileName(f)
+		newFilePath, err := extractPath(path, name)
 		if err != nil {
 			s.logger.Error(err)
 			continue
 		}
-		defer rc.Close()
-
-		newFilePath := fmt.Sprintf("%s/%s", path, f.Name)
 
-		// Directory case
+		// Directories are created for files only, so excluded ones stay empty.
 		if f.FileInfo().IsDir() {
-			err = os.MkdirAll(newFilePath, os.ModePerm)
-			if err != nil {
-				s.logger.Error(err)
-			}
 			continue
 		}
 
-		// File case
-		if destFile, err := os.Create(newFilePath); err != nil {
+		content, sourceName, reason, err := s.readZipFile(f, name)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
+
+		relPath, _ := filepath.Rel(path, newFilePath)
+		relPath = filepath.ToSlash(relPath)
+
+		if reason != "" {
+			excluded = append(excluded, ExcludedFile{Path: relPath, Reason: reason})
+			continue
+		}
+		if sourceName != name {
+			newFilePath += sourceName[len(name):]
+			relPath += sourceName[len(name):]
+		}
+
+		// Files are compared in UTF-8 with the same line endings.
+		hash, size, err := s.blobs.put(bytes.NewReader(charset.Text(content)))
+		if err != nil {
 			s.logger.Error(err)
-		} else if _, err = io.Copy(destFile, rc); err != nil {
+			continue
+		}
+
+		if err = s.blobs.link(hash, newFilePath); err != nil {
 			s.logger.Error(err)
+			continue
 		}
+
+		files = append(files, WorkFile{Path: relPath, Hash: hash, Size: size})
 	}
 
-	return nil
+	return files, excluded, nil
+}
+
+// cloneWork links files of the stored work with the same archive to the directory.
+func (s *service) cloneWork(path string, source WorkEntry) ([]WorkFile, []ExcludedFile, error) {
+	files, err := s.storage.GetWorkFiles(source.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	excluded, err := s.storage.GetExcludedFiles(source.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, file := range files {
+		if err = s.blobs.link(file.Hash, filepath.Join(path, filepath.FromSlash(file.Path))); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return files, excluded, nil
 }
 
 func (s *service) downloadWork(id uint64, url string) (WorkEntry, error) {
+	buf, err := downloadFile(url)
+	if err != nil {
+		return WorkEntry{Id: id}, err
+	}
+	return s.saveArchive(id, buf)
+}
+
+// saveArchive extracts the archive of the work and saves the work to the storage.
+func (s *service) saveArchive(id uint64, buf []byte) (WorkEntry, error) {
 	work := WorkEntry{
-		Path:      s.getWorkPath(id),
-		Timestamp: time.Now(),
+		Id:          id,
+		Path:        s.getWorkPath(id),
+		Timestamp:   time.Now(),
+		Format:      workFormat,
+		ArchiveHash: hashBytes(buf),
 	}
 
 	unzipPath := fmt.Sprintf("%s/%s", work.Path, strconv.FormatUint(id, 10))
@@ -293,18 +586,52 @@ func (s *service) downloadWork(id uint64, url string) (WorkEntry, error) {
 		return work, err
 	}
 
-	buf, err := downloadFile(url)
+	// Identical archive is already stored, so there is no need to extract it.
+	var files []WorkFile
+	var excluded []ExcludedFile
+	cloned := false
+
+	copies, err := s.storage.GetWorksByArchive(work.ArchiveHash)
 	if err != nil {
-		return work, err
+		s.logger.Error(err)
 	}
+	for _, source := range copies {
+		if source.Id == id || source.Format != workFormat {
+			continue
+		}
+		if files, excluded, err = s.cloneWork(unzipPath, source); err == nil {
+			s.logger.Infof("Work %d is an exact copy of work %d", id, source.Id)
+			cloned = true
+			break
+		}
 
-	if err = s.unzipWork(unzipPath, buf); err != nil {
-		return work, err
+		s.logger.Error(err)
+		if err = prepareWorkDirectory(unzipPath); err != nil {
+			return work, err
+		}
+	}
+
+	if !cloned {
+		if files, excluded, err = s.unzipWork(unzipPath, buf); err != nil {
+			return work, err
+		}
+	}
+
+	for _, file := range files {
+		work.Size += file.Size
+	}
+	if len(excluded) != 0 {
+		s.logger.Debugf("Work %d: %d files excluded", id, len(excluded))
 	}
 
-	work.Id, err = s.storage.SaveWork(work.Path, work.Timestamp)
+	added, err := s.storage.SaveWork(work, files, excluded)
 	if err != nil {
-		return work, nil
+		return work, err
+	}
+	s.used += added
+
+	if err = s.indexWork(work); err != nil {
+		s.logger.Error(err)
 	}
 
 	return work, nil
@@ -315,6 +642,7 @@ func (s *service) GetEventWorks(eventId uint64) ([]WorkEntry, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.pins.acquire(eventId, ids)
 
 	works, notFound := s.getWorksEntry(ids)
 	if len(notFound) == 0 {
@@ -331,19 +659,45 @@ func (s *service) GetEventWorks(eventId uint64) ([]WorkEntry, error) {
 	return works, nil
 }
 
-func (s *service) ParseResults(path string) ([]ReportItem, error) {
-	// TODO: parse results
-	return nil, errors.New("not implemented")
+// AddExcludedFiles adds files excluded from both works to the reports.
+func (s *service) AddExcludedFiles(reports []ReportItem) []ReportItem {
+	cache := make(map[uint64][]ExcludedFile)
+	get := func(id uint64) []ExcludedFile {
+		if excluded, ok := cache[id]; ok {
+			return excluded
+		}
+		excluded, err := s.storage.GetExcludedFiles(id)
+		if err != nil {
+			s.logger.Error(err)
+		}
+		cache[id] = excluded
+		return excluded
+	}
+
+	for i := range reports {
+		reports[i].Work1Excluded = get(reports[i].Work1ID)
+		reports[i].Work2Excluded = get(reports[i].Work2ID)
+	}
+	return reports
 }
 
 func (s *service) SendReport(report ReportItem) error {
-	jsonBytes, err := json.Marshal(report)
+	return postJson(urlPostReport, report)
+}
+
+// SendMatrix sends similarities of all pairs of works of the event.
+func (s *service) SendMatrix(matrix MatrixDTO) error {
+	return postJson(urlPostMatrix, matrix)
+}
+
+func postJson(url string, value any) error {
+	jsonBytes, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 
 	data := bytes.NewBuffer(jsonBytes)
-	req, err := router.NewRequest(http.MethodPost, urlPostReport, data)
+	req, err := router.NewRequest(http.MethodPost, url, data)
 	if err != nil {
 		return err
 	}
@@ -356,56 +710,103 @@ func (s *service) SendReport(report ReportItem) error {
 	return nil
 }
 
-func (s *service) removeOldWorks() (uint64, error) {
-	works, err := s.storage.GetOldWorks(10)
-	if err != nil || len(works) == 0 {
-		return 0, err
+// reconcileCacheSize checks the storage against the disk and fixes the total size of blobs.
+// Works without directories or blobs are forgotten, files unknown to the storage are removed.
+func (s *service) reconcileCacheSize() error {
+	works, err := s.storage.GetWorks()
+	if err != nil {
+		return err
 	}
 
-	var removed uint64 = 0
+	known := make(map[string]struct{}, len(works))
+	stale := make([]uint64, 0)
 
 	for _, work := range works {
-		rm, err := utils.GetDirectorySize(work.Path)
-		if err != nil {
-			s.logger.Error(err)
+		// Works of older versions have no blobs or were extracted differently.
+		if _, err = os.Stat(work.Path); err != nil || work.ArchiveHash == "" || work.Format != workFormat {
+			stale = append(stale, work.Id)
 			continue
 		}
+		known[filepath.Clean(work.Path)] = struct{}{}
+	}
 
-		err = os.RemoveAll(work.Path)
+	blobs, err := s.storage.GetBlobs()
+	if err != nil {
+		return err
+	}
+
+	knownBlobs := make(map[string]struct{}, len(blobs))
+	for _, blob := range blobs {
+		if s.blobs.exists(blob.Hash) {
+			knownBlobs[blob.Hash] = struct{}{}
+			continue
+		}
+
+		broken, err := s.storage.GetWorksByBlob(blob.Hash)
 		if err != nil {
-			fmt.Println(err)
+			return err
+		}
+		for _, work := range broken {
+			stale = append(stale, work.Id)
+			delete(known, filepath.Clean(work.Path))
 		}
+	}
 
-		removed += rm
+	if _, err = s.deleteWorks(stale); err != nil {
+		return err
 	}
 
-	ids := make([]uint64, len(works))
-	for i, work := range works {
-		ids[i] = work.Id
+	entries, err := os.ReadDir(s.getWorksPath())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
 	}
 
-	if err = s.storage.DeleteWorks(ids); err != nil {
-		return removed, nil
+	for _, entry := range entries {
+		path := filepath.Join(s.getWorksPath(), entry.Name())
+		if _, ok := known[path]; ok {
+			continue
+		}
+		if err = os.RemoveAll(path); err != nil {
+			s.logger.Error(err)
+		}
 	}
 
-	return removed, nil
-}
+	if err = s.blobs.removeUnknown(knownBlobs); err != nil {
+		s.logger.Error(err)
+	}
 
-func (s *service) CheckCacheSize() error {
-	size, err := utils.GetDirectorySize(s.root)
+	total, err := s.storage.GetTotalSize()
 	if err != nil {
 		return err
 	}
 
-	for s.size < size/1024/1024 {
-		removed, err := s.removeOldWorks()
-		if err != nil {
+	if total != s.used {
+		s.logger.Infof("Cache size reconciled: %d -> %d bytes", s.used, total)
+	}
+	s.used = total
+	s.lastReconcile = time.Now()
+
+	return nil
+}
+
+// ReleaseEvent finishes the task of the event. Works of the event stay pinned
+// for a while after the last task.
+func (s *service) ReleaseEvent(eventId uint64) {
+	s.pins.release(eventId)
+}
+
+func (s *service) CheckCacheSize() error {
+	if s.reconcileInterval != 0 && time.Since(s.lastReconcile) >= s.reconcileInterval {
+		if err := s.reconcileCacheSize(); err != nil {
 			s.logger.Error(err)
-			return err
 		}
+	}
+
+	pinned := s.pins.pinned()
 
-		size -= removed
+	if err := s.removeExpiredWorks(pinned); err != nil {
+		return err
 	}
 
-	return nil
+	return s.removeOldWorks(pinned)
 }
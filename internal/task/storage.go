@@ -3,8 +3,10 @@ package task
 import (
 	"CodeBorrowing/pkg/logger"
 	"database/sql"
+	"errors"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
+	"sort"
 	"strings"
 	"time"
 )
@@ -14,21 +16,152 @@ const (
 	sqlWorkId        = "id"
 	sqlWorkPath      = "path"
 	sqlWorkTimestamp = "time"
+	sqlWorkSize      = "size"
+	sqlWorkArchive   = "archive_hash"
+	sqlWorkFormat    = "format"
+	sqlWorkContent   = "content_hash"
+
+	sqlFilesTable = "sqlWorkFilesTable"
+	sqlFileWork   = "work_id"
+	sqlFilePath   = "path"
+	sqlFileHash   = "hash"
+
+	sqlExcludedTable  = "sqlExcludedFilesTable"
+	sqlExcludedWork   = "work_id"
+	sqlExcludedPath   = "path"
+	sqlExcludedReason = "reason"
+
+	sqlBlobsTable  = "sqlBlobsTable"
+	sqlBlobHash    = "hash"
+	sqlBlobSize    = "size"
+	sqlBlobRefs    = "refs"
+	sqlBlobNorm    = "norm_hash"
+	sqlBlobLines   = "lines"
+	sqlBlobNormKey = "norm_key"
+	sqlBlobFpKey   = "fingerprint_key"
+
+	sqlFingerprintsTable = "sqlFingerprintsTable"
+	sqlFingerprintBlob   = "blob_hash"
+	sqlFingerprintValue  = "fingerprint"
+
+	sqlPairsTable = "sqlPairResultsTable"
+	sqlPairHash1  = "hash1"
+	sqlPairHash2  = "hash2"
+	sqlPairKey    = "result_key"
+	sqlPairReport = "report"
+
+	sqlTimeFormat = "2006-01-02 15:04:05"
 )
 
-var queryCreateTable = fmt.Sprintf("create table if not exists %s (%s integer primary key autoincrement, %s text, %s text)", sqlWorksTable, sqlWorkId, sqlWorkPath, sqlWorkTimestamp)
-var queryGetWork = fmt.Sprintf("select %s, %s, %s from %s where %s = $1", sqlWorkId, sqlWorkPath, sqlWorkTimestamp, sqlWorksTable, sqlWorkId)
-var querySaveWork = fmt.Sprintf("insert into %s (%s, %s) values ($1, $2)", sqlWorksTable, sqlWorkPath, sqlWorkTimestamp)
-var queryUpdateWorksTimestamp = fmt.Sprintf("update %s set %s = $1 where %s in ($2)", sqlWorksTable, sqlWorkTimestamp, sqlWorkId)
-var queryGetOldWorks = fmt.Sprintf("select %s, %s, %s from %s order by %s LIMIT $1", sqlWorkId, sqlWorkPath, sqlWorkTimestamp, sqlWorksTable, sqlWorkTimestamp)
-var queryDeleteWorks = fmt.Sprintf("delete from %s where %s in ($1)", sqlWorksTable, sqlWorkId)
+var sqlWorkColumns = fmt.Sprintf("%s, %s, %s, %s, %s, %s", sqlWorkId, sqlWorkPath, sqlWorkTimestamp, sqlWorkSize, sqlWorkArchive, sqlWorkFormat)
+
+var queryCreateTable = fmt.Sprintf("create table if not exists %s (%s integer primary key autoincrement, %s text, %s text, %s integer not null default 0, %s text not null default '', %s integer not null default 0, %s text not null default '')", sqlWorksTable, sqlWorkId, sqlWorkPath, sqlWorkTimestamp, sqlWorkSize, sqlWorkArchive, sqlWorkFormat, sqlWorkContent)
+var queryCreateFilesTable = fmt.Sprintf("create table if not exists %s (%s integer not null, %s text not null, %s text not null, primary key (%s, %s))", sqlFilesTable, sqlFileWork, sqlFilePath, sqlFileHash, sqlFileWork, sqlFilePath)
+var queryCreateExcludedTable = fmt.Sprintf("create table if not exists %s (%s integer not null, %s text not null, %s text not null, primary key (%s, %s))", sqlExcludedTable, sqlExcludedWork, sqlExcludedPath, sqlExcludedReason, sqlExcludedWork, sqlExcludedPath)
+var queryCreateBlobsTable = fmt.Sprintf("create table if not exists %s (%s text primary key, %s integer not null, %s integer not null)", sqlBlobsTable, sqlBlobHash, sqlBlobSize, sqlBlobRefs)
+var queryCreateFingerprintsTable = fmt.Sprintf("create table if not exists %s (%s text not null, %s integer not null)", sqlFingerprintsTable, sqlFingerprintBlob, sqlFingerprintValue)
+var queryCreatePairsTable = fmt.Sprintf("create table if not exists %s (%s text not null, %s text not null, %s text not null, %s text not null, primary key (%s, %s, %s))", sqlPairsTable, sqlPairHash1, sqlPairHash2, sqlPairKey, sqlPairReport, sqlPairHash1, sqlPairHash2, sqlPairKey)
+var queryCreateFingerprintIndex = fmt.Sprintf("create index if not exists idx_%s_%s on %s (%s)", sqlFingerprintsTable, sqlFingerprintValue, sqlFingerprintsTable, sqlFingerprintValue)
+var queryCreateFingerprintBlobIndex = fmt.Sprintf("create index if not exists idx_%s_%s on %s (%s)", sqlFingerprintsTable, sqlFingerprintBlob, sqlFingerprintsTable, sqlFingerprintBlob)
+var queryCreateFilesHashIndex = fmt.Sprintf("create index if not exists idx_%s_%s on %s (%s)", sqlFilesTable, sqlFileHash, sqlFilesTable, sqlFileHash)
+var queryCreateArchiveIndex = fmt.Sprintf("create index if not exists idx_%s_%s on %s (%s)", sqlWorksTable, sqlWorkArchive, sqlWorksTable, sqlWorkArchive)
+var queryTableColumns = "select name from pragma_table_info($1)"
+var queryAddColumn = "alter table %s add column %s"
+
+var queryGetWork = fmt.Sprintf("select %s from %s where %s = $1", sqlWorkColumns, sqlWorksTable, sqlWorkId)
+var queryGetWorks = fmt.Sprintf("select %s from %s", sqlWorkColumns, sqlWorksTable)
+var queryGetWorksByArchive = fmt.Sprintf("select %s from %s where %s = $1", sqlWorkColumns, sqlWorksTable, sqlWorkArchive)
+var queryGetWorksByBlob = fmt.Sprintf("select %s from %s where %s in (select %s from %s where %s = $1)", sqlWorkColumns, sqlWorksTable, sqlWorkId, sqlFileWork, sqlFilesTable, sqlFileHash)
+var querySaveWork = fmt.Sprintf("insert into %s (%s, %s) values ($1, $2, $3, $4, $5, $6, $7)", sqlWorksTable, sqlWorkColumns, sqlWorkContent)
+var queryWorkExists = fmt.Sprintf("select count(*) from %s where %s = $1", sqlWorksTable, sqlWorkId)
+var queryUpdateWorksTimestamp = fmt.Sprintf("update %s set %s = ? where %s in (%%s)", sqlWorksTable, sqlWorkTimestamp, sqlWorkId)
+var queryGetOldWorks = fmt.Sprintf("select %s from %s order by %s, %s LIMIT $1 OFFSET $2", sqlWorkColumns, sqlWorksTable, sqlWorkTimestamp, sqlWorkId)
+var queryGetWorksUsedBefore = fmt.Sprintf("select %s from %s where %s < $1", sqlWorkColumns, sqlWorksTable, sqlWorkTimestamp)
+var queryDeleteWorks = fmt.Sprintf("delete from %s where %s in (%%s)", sqlWorksTable, sqlWorkId)
+var queryGetWorksWithoutContent = fmt.Sprintf("select %s from %s where %s = ''", sqlWorkId, sqlWorksTable, sqlWorkContent)
+var queryUpdateWorkContent = fmt.Sprintf("update %s set %s = $1 where %s = $2", sqlWorksTable, sqlWorkContent, sqlWorkId)
+
+var queryGetWorkFiles = fmt.Sprintf("select f.%s, f.%s, b.%s, b.%s, b.%s, b.%s from %s f join %s b on f.%s = b.%s where f.%s = $1 order by f.%s", sqlFilePath, sqlFileHash, sqlBlobSize, sqlBlobNorm, sqlBlobNormKey, sqlBlobLines, sqlFilesTable, sqlBlobsTable, sqlFileHash, sqlBlobHash, sqlFileWork, sqlFilePath)
+var querySaveWorkFile = fmt.Sprintf("insert or replace into %s (%s, %s, %s) values ($1, $2, $3)", sqlFilesTable, sqlFileWork, sqlFilePath, sqlFileHash)
+var queryReleaseWorkFiles = fmt.Sprintf("update %s set %s = %s - (select count(*) from %s f where f.%s = %s.%s and f.%s in (%%s)) where %s in (select %s from %s where %s in (%%s))", sqlBlobsTable, sqlBlobRefs, sqlBlobRefs, sqlFilesTable, sqlFileHash, sqlBlobsTable, sqlBlobHash, sqlFileWork, sqlBlobHash, sqlFileHash, sqlFilesTable, sqlFileWork)
+var queryDeleteWorkFiles = fmt.Sprintf("delete from %s where %s in (%%s)", sqlFilesTable, sqlFileWork)
+
+var queryGetExcludedFiles = fmt.Sprintf("select %s, %s from %s where %s = $1 order by %s", sqlExcludedPath, sqlExcludedReason, sqlExcludedTable, sqlExcludedWork, sqlExcludedPath)
+var querySaveExcludedFile = fmt.Sprintf("insert or replace into %s (%s, %s, %s) values ($1, $2, $3)", sqlExcludedTable, sqlExcludedWork, sqlExcludedPath, sqlExcludedReason)
+var queryDeleteExcludedFiles = fmt.Sprintf("delete from %s where %s in (%%s)", sqlExcludedTable, sqlExcludedWork)
+
+var queryGetBlobRefs = fmt.Sprintf("select %s from %s where %s = $1", sqlBlobRefs, sqlBlobsTable, sqlBlobHash)
+var querySaveBlob = fmt.Sprintf("insert into %s (%s, %s, %s) values ($1, $2, 1) on conflict(%s) do update set %s = %s + 1", sqlBlobsTable, sqlBlobHash, sqlBlobSize, sqlBlobRefs, sqlBlobHash, sqlBlobRefs, sqlBlobRefs)
+var queryGetBlobs = fmt.Sprintf("select %s, %s from %s", sqlBlobHash, sqlBlobSize, sqlBlobsTable)
+var queryGetUnusedBlobs = fmt.Sprintf("select %s, %s from %s where %s <= 0", sqlBlobHash, sqlBlobSize, sqlBlobsTable, sqlBlobRefs)
+var queryDeleteUnusedFingerprints = fmt.Sprintf("delete from %s where %s in (select %s from %s where %s <= 0)", sqlFingerprintsTable, sqlFingerprintBlob, sqlBlobHash, sqlBlobsTable, sqlBlobRefs)
+var queryDeleteUnusedBlobs = fmt.Sprintf("delete from %s where %s <= 0", sqlBlobsTable, sqlBlobRefs)
+var queryGetTotalSize = fmt.Sprintf("select coalesce(sum(%s), 0) from %s", sqlBlobSize, sqlBlobsTable)
+var queryGetUnindexedBlobs = fmt.Sprintf("select b.%s, min(f.%s) from %s b join %s f on f.%s = b.%s where f.%s = $1 and b.%s != $2 group by b.%s", sqlBlobHash, sqlFilePath, sqlBlobsTable, sqlFilesTable, sqlFileHash, sqlBlobHash, sqlFileWork, sqlBlobFpKey, sqlBlobHash)
+var queryDeleteFingerprints = fmt.Sprintf("delete from %s where %s = $1", sqlFingerprintsTable, sqlFingerprintBlob)
+var querySaveFingerprint = fmt.Sprintf("insert into %s (%s, %s) values ($1, $2)", sqlFingerprintsTable, sqlFingerprintBlob, sqlFingerprintValue)
+var queryUpdateBlobFpKey = fmt.Sprintf("update %s set %s = $1 where %s = $2", sqlBlobsTable, sqlBlobFpKey, sqlBlobHash)
+var queryGetCandidates = fmt.Sprintf(`select f2.%s, count(distinct p2.%s) as shared
+from %s f1
+join %s p1 on p1.%s = f1.%s
+join %s p2 on p2.%s = p1.%s
+join %s f2 on f2.%s = p2.%s
+where f1.%s = ? and f2.%s in (%%s)
+group by f2.%s order by shared desc limit ?`,
+	sqlFileWork, sqlFingerprintValue,
+	sqlFilesTable,
+	sqlFingerprintsTable, sqlFingerprintBlob, sqlFileHash,
+	sqlFingerprintsTable, sqlFingerprintValue, sqlFingerprintValue,
+	sqlFilesTable, sqlFileHash, sqlFingerprintBlob,
+	sqlFileWork, sqlFileWork,
+	sqlFileWork)
+var queryGetWorksFingerprints = fmt.Sprintf("select distinct p.%s from %s p join %s f on f.%s = p.%s where f.%s in (%%s)", sqlFingerprintValue, sqlFingerprintsTable, sqlFilesTable, sqlFileHash, sqlFingerprintBlob, sqlFileWork)
+var queryGetPairResult = fmt.Sprintf("select %s from %s where %s = $1 and %s = $2 and %s = $3", sqlPairReport, sqlPairsTable, sqlPairHash1, sqlPairHash2, sqlPairKey)
+var querySavePairResult = fmt.Sprintf("insert or replace into %s (%s, %s, %s, %s) values ($1, $2, $3, $4)", sqlPairsTable, sqlPairHash1, sqlPairHash2, sqlPairKey, sqlPairReport)
+var queryDeleteOtherPairResults = fmt.Sprintf("delete from %s where %s != $1", sqlPairsTable, sqlPairKey)
+var queryDeleteUnusedPairResults = fmt.Sprintf("delete from %s where %s not in (select %s from %s) or %s not in (select %s from %s)", sqlPairsTable, sqlPairHash1, sqlWorkContent, sqlWorksTable, sqlPairHash2, sqlWorkContent, sqlWorksTable)
+var queryUpdateBlobNorm = fmt.Sprintf("update %s set %s = $1, %s = $2, %s = $3 where %s = $4", sqlBlobsTable, sqlBlobNorm, sqlBlobNormKey, sqlBlobLines, sqlBlobHash)
+
+// Columns added to tables after their first versions.
+var sqlMigrations = map[string]map[string]string{
+	sqlWorksTable: {
+		sqlWorkSize:    fmt.Sprintf("%s integer not null default 0", sqlWorkSize),
+		sqlWorkArchive: fmt.Sprintf("%s text not null default ''", sqlWorkArchive),
+		sqlWorkFormat:  fmt.Sprintf("%s integer not null default 0", sqlWorkFormat),
+		sqlWorkContent: fmt.Sprintf("%s text not null default ''", sqlWorkContent),
+	},
+	sqlBlobsTable: {
+		sqlBlobNorm:    fmt.Sprintf("%s text not null default ''", sqlBlobNorm),
+		sqlBlobLines:   fmt.Sprintf("%s integer not null default 0", sqlBlobLines),
+		sqlBlobFpKey:   fmt.Sprintf("%s text not null default ''", sqlBlobFpKey),
+		sqlBlobNormKey: fmt.Sprintf("%s text not null default ''", sqlBlobNormKey),
+	},
+}
+
+var ErrWorkExists = errors.New("work is already saved")
 
 type Storage interface {
 	GetWork(id uint64) (WorkEntry, error)
-	SaveWork(path string, timestamp time.Time) (uint64, error)
+	GetWorks() ([]WorkEntry, error)
+	GetWorksByArchive(hash string) ([]WorkEntry, error)
+	GetWorksByBlob(hash string) ([]WorkEntry, error)
+	GetWorkFiles(id uint64) ([]WorkFile, error)
+	GetExcludedFiles(id uint64) ([]ExcludedFile, error)
+	SaveWork(work WorkEntry, files []WorkFile, excluded []ExcludedFile) (uint64, error)
 	UpdateWorksTimestamp(ids []uint64, timestamp time.Time) error
-	GetOldWorks(count uint64) ([]WorkEntry, error)
-	DeleteWorks(ids []uint64) error
+	GetBlobs() ([]BlobEntry, error)
+	UpdateBlobNorm(hash string, normHash string, normKey string, lines uint64) error
+	GetUnindexedBlobs(workId uint64, key string) ([]WorkFile, error)
+	SaveFingerprints(hash string, key string, fingerprints []uint64) error
+	GetCandidates(newId uint64, oldIds []uint64, count uint64) ([]CandidateEntry, error)
+	GetWorksFingerprints(ids []uint64) (map[uint64]struct{}, error)
+	GetTotalSize() (uint64, error)
+	GetOldWorks(count uint64, offset uint64) ([]WorkEntry, error)
+	GetWorksUsedBefore(timestamp time.Time) ([]WorkEntry, error)
+	DeleteWorks(ids []uint64) ([]BlobEntry, error)
+	GetPairResult(hash1 string, hash2 string, key string) (string, bool, error)
+	SavePairResults(key string, results []PairResult) error
+	DeletePairResults(keepKey string) error
 	Close() error
 }
 
@@ -52,55 +185,286 @@ func NewStorage(appLogger *logger.Logger, path string) (Storage, error) {
 		return nil, err
 	}
 
+	for _, query := range []string{queryCreateFilesTable, queryCreateExcludedTable, queryCreateBlobsTable, queryCreateFingerprintsTable, queryCreatePairsTable,
+		queryCreateFingerprintIndex, queryCreateFingerprintBlobIndex, queryCreateFilesHashIndex} {
+		if _, err = db.Exec(query); err != nil {
+			return nil, err
+		}
+	}
+
+	for table, columns := range sqlMigrations {
+		if err = migrateTable(db, table, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = db.Exec(queryCreateArchiveIndex); err != nil {
+		return nil, err
+	}
+
 	data := &storage{
 		appLogger: appLogger,
 		db:        db,
 	}
 
+	if err = data.fillContentHashes(); err != nil {
+		return nil, err
+	}
+
 	return data, nil
 }
 
+// fillContentHashes sets content hashes of works saved by older versions,
+// so their stored results of pairs are kept.
+func (s *storage) fillContentHashes() error {
+	res, err := s.db.Query(queryGetWorksWithoutContent)
+	if err != nil {
+		return err
+	}
+	var ids []uint64
+	for res.Next() {
+		var id uint64
+		if err = res.Scan(&id); err != nil {
+			_ = res.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	_ = res.Close()
+
+	for _, id := range ids {
+		files, err := s.GetWorkFiles(id)
+		if err != nil {
+			return err
+		}
+		if _, err = s.db.Exec(queryUpdateWorkContent, workContentHash(files), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTable adds missing columns to tables created by older versions.
+// Works of older versions are removed by the next reconciliation.
+func migrateTable(db *sql.DB, table string, columns map[string]string) error {
+	rows, err := db.Query(queryTableColumns, table)
+	if err != nil {
+		return err
+	}
+
+	existed := make(map[string]struct{})
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		existed[name] = struct{}{}
+	}
+	_ = rows.Close()
+
+	for column, definition := range columns {
+		if _, ok := existed[column]; ok {
+			continue
+		}
+		if _, err = db.Exec(fmt.Sprintf(queryAddColumn, table, definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *storage) Close() error {
-	if err := s.Close(); err != nil {
+	if err := s.db.Close(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func toSqlRow(ids []uint64) string {
-	return strings.Trim(strings.Join(strings.Split(fmt.Sprint(ids), " "), ", "), "[]")
+// toSqlRow makes a list of placeholders and arguments for the "in" condition.
+func toSqlRow(ids []uint64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
 }
 
-func (s *storage) GetWork(id uint64) (WorkEntry, error) {
-	res := s.db.QueryRow(queryGetWork, id)
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWork(row scanner) (WorkEntry, error) {
+	var work WorkEntry
+	var timeStr string
+
+	if err := row.Scan(&work.Id, &work.Path, &timeStr, &work.Size, &work.ArchiveHash, &work.Format); err != nil {
+		return work, err
+	}
 
-	work := WorkEntry{}
-	err := res.Scan(&work.Id, &work.Path, &work.Timestamp)
+	timestamp, err := time.ParseInLocation(sqlTimeFormat, timeStr, time.Local)
 	if err != nil {
 		return work, err
 	}
+	work.Timestamp = timestamp
 
 	return work, nil
 }
 
-func (s *storage) SaveWork(path string, timestamp time.Time) (uint64, error) {
-	timeStr := timestamp.Format("2006-01-02 15:04:05")
-	res, err := s.db.Exec(querySaveWork, path, timeStr)
+func (s *storage) scanWorks(res *sql.Rows) []WorkEntry {
+	var works []WorkEntry
+
+	for res.Next() { // Iterate and fetch the records from result cursor
+		work, err := scanWork(res)
+		if err != nil {
+			s.appLogger.Error(err)
+			continue
+		}
+		works = append(works, work)
+	}
+
+	return works
+}
+
+func (s *storage) GetWork(id uint64) (WorkEntry, error) {
+	return scanWork(s.db.QueryRow(queryGetWork, id))
+}
+
+func (s *storage) queryWorks(query string, args ...any) ([]WorkEntry, error) {
+	res, err := s.db.Query(query, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer res.Close()
+
+	return s.scanWorks(res), nil
+}
+
+func (s *storage) GetWorks() ([]WorkEntry, error) {
+	return s.queryWorks(queryGetWorks)
+}
+
+func (s *storage) GetWorksByArchive(hash string) ([]WorkEntry, error) {
+	return s.queryWorks(queryGetWorksByArchive, hash)
+}
 
-	id, err := res.LastInsertId()
+func (s *storage) GetWorksByBlob(hash string) ([]WorkEntry, error) {
+	return s.queryWorks(queryGetWorksByBlob, hash)
+}
+
+func (s *storage) GetWorkFiles(id uint64) ([]WorkFile, error) {
+	res, err := s.db.Query(queryGetWorkFiles, id)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var files []WorkFile
+	for res.Next() {
+		var file WorkFile
+		if err = res.Scan(&file.Path, &file.Hash, &file.Size, &file.NormHash, &file.NormKey, &file.Lines); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func (s *storage) GetExcludedFiles(id uint64) ([]ExcludedFile, error) {
+	res, err := s.db.Query(queryGetExcludedFiles, id)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var files []ExcludedFile
+	for res.Next() {
+		var file ExcludedFile
+		if err = res.Scan(&file.Path, &file.Reason); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// SaveWork saves the new work with its files and references the blobs of the files.
+// The saved work must be deleted first, so references of its blobs are released.
+// Returns the size of blobs, which were not referenced before.
+func (s *storage) SaveWork(work WorkEntry, files []WorkFile, excluded []ExcludedFile) (uint64, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
+
+	var count uint64
+	if err = tx.QueryRow(queryWorkExists, work.Id).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count != 0 {
+		return 0, fmt.Errorf("%w: %d", ErrWorkExists, work.Id)
+	}
+
+	// Archives may have several entries with the same path, the last one is extracted.
+	last := make(map[string]int, len(files))
+	for i, file := range files {
+		last[file.Path] = i
+	}
+	saved := make([]WorkFile, 0, len(last))
+	for i, file := range files {
+		if last[file.Path] == i {
+			saved = append(saved, file)
+		}
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Path < saved[j].Path })
+
+	timeStr := work.Timestamp.Format(sqlTimeFormat)
+	if _, err = tx.Exec(querySaveWork, work.Id, work.Path, timeStr, work.Size, work.ArchiveHash, work.Format, workContentHash(saved)); err != nil {
+		return 0, err
+	}
+
+	var added uint64 = 0
+	for _, file := range saved {
+		var refs int64
+		err = tx.QueryRow(queryGetBlobRefs, file.Hash).Scan(&refs)
+		if errors.Is(err, sql.ErrNoRows) {
+			added += file.Size
+		} else if err != nil {
+			return 0, err
+		}
+
+		if _, err = tx.Exec(querySaveBlob, file.Hash, file.Size); err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec(querySaveWorkFile, work.Id, file.Path, file.Hash); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, file := range excluded {
+		if _, err = tx.Exec(querySaveExcludedFile, work.Id, file.Path, file.Reason); err != nil {
+			return 0, err
+		}
+	}
 
-	return uint64(id), nil
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return added, nil
 }
 
 func (s *storage) UpdateWorksTimestamp(ids []uint64, timestamp time.Time) error {
-	timeStr := timestamp.Format("2006-01-02 15:04:05")
-	res, err := s.db.Exec(queryUpdateWorksTimestamp, toSqlRow(ids), timeStr)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders, args := toSqlRow(ids)
+	timeStr := timestamp.Format(sqlTimeFormat)
+	res, err := s.db.Exec(fmt.Sprintf(queryUpdateWorksTimestamp, placeholders), append([]any{timeStr}, args...)...)
 	if err != nil {
 		return err
 	}
@@ -112,37 +476,243 @@ func (s *storage) UpdateWorksTimestamp(ids []uint64, timestamp time.Time) error
 	return nil
 }
 
-func (s *storage) GetOldWorks(count uint64) ([]WorkEntry, error) {
-	res, err := s.db.Query(queryGetOldWorks, count)
+func scanBlobs(res *sql.Rows) ([]BlobEntry, error) {
+	var blobs []BlobEntry
+	for res.Next() {
+		var blob BlobEntry
+		if err := res.Scan(&blob.Hash, &blob.Size); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs, nil
+}
+
+func (s *storage) GetBlobs() ([]BlobEntry, error) {
+	res, err := s.db.Query(queryGetBlobs)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Close()
 
-	var works []WorkEntry
+	return scanBlobs(res)
+}
 
-	for res.Next() { // Iterate and fetch the records from result cursor
-		var work WorkEntry
-		err = res.Scan(&work.Id, &work.Path, &work.Timestamp)
-		if err != nil {
-			s.appLogger.Error(err)
-			continue
+// UpdateBlobNorm saves the hash and the count of lines of the normalized content
+// with the key of the normalization.
+func (s *storage) UpdateBlobNorm(hash string, normHash string, normKey string, lines uint64) error {
+	_, err := s.db.Exec(queryUpdateBlobNorm, normHash, normKey, lines, hash)
+	return err
+}
+
+// GetUnindexedBlobs returns blobs of the work without fingerprints made with the key.
+// Every blob is returned once with one of the paths of its files.
+func (s *storage) GetUnindexedBlobs(workId uint64, key string) ([]WorkFile, error) {
+	res, err := s.db.Query(queryGetUnindexedBlobs, workId, key)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var files []WorkFile
+	for res.Next() {
+		var file WorkFile
+		if err = res.Scan(&file.Hash, &file.Path); err != nil {
+			return nil, err
 		}
-		works = append(works, work)
+		files = append(files, file)
 	}
+	return files, nil
+}
 
-	return works, nil
+// SaveFingerprints replaces fingerprints of the blob.
+func (s *storage) SaveFingerprints(hash string, key string, fingerprints []uint64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(queryDeleteFingerprints, hash); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(querySaveFingerprint)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, fp := range fingerprints {
+		if _, err = stmt.Exec(hash, int64(fp)); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(queryUpdateBlobFpKey, key, hash); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *storage) DeleteWorks(ids []uint64) error {
+// GetCandidates returns old works ordered by the count of fingerprints shared with the new work.
+// Works without shared fingerprints are not returned.
+func (s *storage) GetCandidates(newId uint64, oldIds []uint64, count uint64) ([]CandidateEntry, error) {
+	if len(oldIds) == 0 {
+		return nil, nil
+	}
+
+	placeholders, args := toSqlRow(oldIds)
+	args = append(append([]any{newId}, args...), count)
+	res, err := s.db.Query(fmt.Sprintf(queryGetCandidates, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var candidates []CandidateEntry
+	for res.Next() {
+		var candidate CandidateEntry
+		if err = res.Scan(&candidate.WorkId, &candidate.Shared); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// GetWorksFingerprints returns the union of fingerprints of all files of the works.
+func (s *storage) GetWorksFingerprints(ids []uint64) (map[uint64]struct{}, error) {
+	result := make(map[uint64]struct{})
 	if len(ids) == 0 {
-		return nil
+		return result, nil
+	}
+
+	placeholders, args := toSqlRow(ids)
+	res, err := s.db.Query(fmt.Sprintf(queryGetWorksFingerprints, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var fp int64
+		if err = res.Scan(&fp); err != nil {
+			return nil, err
+		}
+		result[uint64(fp)] = struct{}{}
+	}
+	return result, nil
+}
+
+// GetTotalSize returns the size of all blobs.
+func (s *storage) GetTotalSize() (uint64, error) {
+	var size uint64
+	if err := s.db.QueryRow(queryGetTotalSize).Scan(&size); err != nil {
+		return 0, err
 	}
+	return size, nil
+}
+
+func (s *storage) GetOldWorks(count uint64, offset uint64) ([]WorkEntry, error) {
+	return s.queryWorks(queryGetOldWorks, count, offset)
+}
 
-	idStr := strings.Trim(strings.Join(strings.Split(fmt.Sprint(ids), " "), ","), "[]")
-	_, err := s.db.Exec(queryDeleteWorks, idStr)
+func (s *storage) GetWorksUsedBefore(timestamp time.Time) ([]WorkEntry, error) {
+	return s.queryWorks(queryGetWorksUsedBefore, timestamp.Format(sqlTimeFormat))
+}
+
+// DeleteWorks deletes the works with their files and returns blobs,
+// which are not referenced anymore. Such blobs are deleted from the storage too,
+// as well as results of pairs with contents, which no work has anymore.
+func (s *storage) DeleteWorks(ids []uint64) ([]BlobEntry, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	placeholders, args := toSqlRow(ids)
+	releaseArgs := append(append([]any{}, args...), args...)
+	if _, err = tx.Exec(fmt.Sprintf(queryReleaseWorkFiles, placeholders, placeholders), releaseArgs...); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(fmt.Sprintf(queryDeleteWorkFiles, placeholders), args...); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(fmt.Sprintf(queryDeleteExcludedFiles, placeholders), args...); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(fmt.Sprintf(queryDeleteWorks, placeholders), args...); err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Query(queryGetUnusedBlobs)
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := scanBlobs(res)
+	_ = res.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(queryDeleteUnusedFingerprints); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(queryDeleteUnusedBlobs); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(queryDeleteUnusedPairResults); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// GetPairResult returns the stored result of the pair of works with the key.
+func (s *storage) GetPairResult(hash1 string, hash2 string, key string) (string, bool, error) {
+	var report string
+	err := s.db.QueryRow(queryGetPairResult, hash1, hash2, key).Scan(&report)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return report, true, nil
+}
+
+// SavePairResults saves results of pairs of works with the key.
+func (s *storage) SavePairResults(key string, results []PairResult) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(querySavePairResult)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, result := range results {
+		if _, err = stmt.Exec(result.Hash1, result.Hash2, key, result.Report); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeletePairResults deletes results of pairs made with other keys.
+func (s *storage) DeletePairResults(keepKey string) error {
+	_, err := s.db.Exec(queryDeleteOtherPairResults, keepKey)
+	return err
 }
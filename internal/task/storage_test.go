@@ -0,0 +1,104 @@
+package task
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) Storage {
+	t.Helper()
+	storage, err := NewStorage(nil, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+func TestSaveWorkRejectsSavedWork(t *testing.T) {
+	storage := newTestStorage(t)
+	work := WorkEntry{Id: 1, Path: "works/1", Timestamp: time.Now(), Format: workFormat}
+	files := []WorkFile{{Path: "a.cs", Hash: "h1", Size: 10}}
+
+	added, err := storage.SaveWork(work, files, nil)
+	if err != nil || added != 10 {
+		t.Fatalf("SaveWork() = %d, %v", added, err)
+	}
+	if _, err = storage.SaveWork(work, files, nil); !errors.Is(err, ErrWorkExists) {
+		t.Fatalf("SaveWork() of the saved work: %v, want %v", err, ErrWorkExists)
+	}
+
+	blobs, err := storage.DeleteWorks([]uint64{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0].Hash != "h1" {
+		t.Errorf("DeleteWorks() freed %v, want the blob h1", blobs)
+	}
+}
+
+func TestSaveWorkDuplicatePaths(t *testing.T) {
+	storage := newTestStorage(t)
+	work := WorkEntry{Id: 1, Path: "works/1", Timestamp: time.Now(), Format: workFormat}
+	files := []WorkFile{{Path: "a.cs", Hash: "h1", Size: 10}, {Path: "a.cs", Hash: "h2", Size: 20}}
+
+	if _, err := storage.SaveWork(work, files, nil); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := storage.GetWorkFiles(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].Hash != "h2" {
+		t.Fatalf("GetWorkFiles() = %v, want the last entry", saved)
+	}
+
+	blobs, err := storage.DeleteWorks([]uint64{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0].Hash != "h2" {
+		t.Errorf("DeleteWorks() freed %v, want the blob h2", blobs)
+	}
+}
+
+func TestDeleteWorksPrunesPairResults(t *testing.T) {
+	storage := newTestStorage(t)
+	files := map[uint64][]WorkFile{
+		1: {{Path: "a.cs", Hash: "h1", Size: 10}},
+		2: {{Path: "a.cs", Hash: "h2", Size: 10}},
+		3: {{Path: "a.cs", Hash: "h3", Size: 10}},
+	}
+	hashes := make(map[uint64]string)
+	for id, workFiles := range files {
+		work := WorkEntry{Id: id, Path: "works", Timestamp: time.Now(), Format: workFormat}
+		if _, err := storage.SaveWork(work, workFiles, nil); err != nil {
+			t.Fatal(err)
+		}
+		hashes[id] = workContentHash(workFiles)
+	}
+
+	pairs := []PairResult{
+		{Hash1: hashes[1], Hash2: hashes[2]},
+		{Hash1: hashes[1], Hash2: hashes[3]},
+		{Hash1: hashes[2], Hash2: hashes[3]},
+	}
+	if err := storage.SavePairResults("key", pairs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.DeleteWorks([]uint64{3}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range pairs {
+		_, ok, err := storage.GetPairResult(pair.Hash1, pair.Hash2, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := pair.Hash1 != hashes[3] && pair.Hash2 != hashes[3]
+		if ok != want {
+			t.Errorf("pair %s-%s is stored: %v, want %v", pair.Hash1, pair.Hash2, ok, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package task
+
+import "sort"
+
+// sweep compares every pair of works of the event once. Each work is checked against
+// works submitted before it, so late submissions are compared with each other too.
+// Works of reference corpora are compared with every work, but they are not a part of the matrix.
+// Reports are passed to the sink as by usual tasks, the similarity matrix is sent at the end.
+func (h *handler) sweep(eventId uint64, works []WorkEntry, isBase map[uint64]struct{}, known KnownCode,
+	references []ReferenceWork, send func([]ReportItem)) {
+	submitted := make([]WorkEntry, 0, len(works))
+	for _, work := range works {
+		if _, ok := isBase[work.Id]; !ok {
+			submitted = append(submitted, work)
+		}
+	}
+	// Ids grow with time of submission.
+	sort.Slice(submitted, func(i, j int) bool { return submitted[i].Id < submitted[j].Id })
+
+	matrix := newSimilarityMatrix(eventId, submitted)
+	sink := func(reports []ReportItem) {
+		matrix.add(reports)
+		send(reports)
+	}
+
+	for i := range submitted {
+		h.reportExternal(submitted[i], known, sink)
+
+		oldWorks := append([]WorkEntry{}, submitted[:i]...)
+		for _, reference := range references {
+			oldWorks = append(oldWorks, reference.WorkEntry)
+		}
+		if len(oldWorks) != 0 {
+			h.checkWork(submitted[i], oldWorks, known, false, sink)
+		}
+	}
+
+	if err := h.service.SendMatrix(matrix.MatrixDTO); err != nil {
+		h.logger.Error(err)
+	}
+	h.logger.Infof("Sweep of event %d: %d works compared", eventId, len(submitted))
+}
+
+// similarityMatrix collects max similarities of pairs from reports.
+type similarityMatrix struct {
+	MatrixDTO
+	index map[uint64]int // Work -> row.
+}
+
+func newSimilarityMatrix(eventId uint64, works []WorkEntry) *similarityMatrix {
+	m := &similarityMatrix{
+		MatrixDTO: MatrixDTO{
+			EventID: eventId,
+			Works:   make([]uint64, len(works)),
+			Avg:     make([][]float64, len(works)),
+			Max:     make([][]float64, len(works)),
+		},
+		index: make(map[uint64]int, len(works)),
+	}
+
+	for i, work := range works {
+		m.Works[i] = work.Id
+		m.index[work.Id] = i
+		m.Avg[i] = make([]float64, len(works))
+		m.Max[i] = make([]float64, len(works))
+		m.Avg[i][i], m.Max[i][i] = 100, 100
+	}
+	return m
+}
+
+// add sets similarities of pairs of the reports. Each pair is reported once,
+// but the highest similarity is kept, if the pair is reported again.
+func (m *similarityMatrix) add(reports []ReportItem) {
+	for _, report := range reports {
+		i, ok1 := m.index[report.Work1ID]
+		j, ok2 := m.index[report.Work2ID]
+		if !ok1 || !ok2 || i == j {
+			continue
+		}
+
+		avg, maxSim := max(m.Avg[i][j], report.Avg), max(m.Max[i][j], report.Max)
+		m.Avg[i][j], m.Avg[j][i] = avg, avg
+		m.Max[i][j], m.Max[j][i] = maxSim, maxSim
+	}
+}
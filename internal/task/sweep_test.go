@@ -0,0 +1,86 @@
+package task
+
+import (
+	"CodeBorrowing/pkg/logger"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSimilarityMatrixAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		reports []ReportItem
+		avg     [][]float64
+		max     [][]float64
+	}{
+		{
+			name:    "symmetric",
+			reports: []ReportItem{{Work1ID: 3, Work2ID: 1, Avg: 40, Max: 50}},
+			avg:     [][]float64{{100, 0, 40}, {0, 100, 0}, {40, 0, 100}},
+			max:     [][]float64{{100, 0, 50}, {0, 100, 0}, {50, 0, 100}},
+		},
+		{
+			name:    "repeated pair keeps the highest",
+			reports: []ReportItem{{Work1ID: 1, Work2ID: 2, Avg: 40, Max: 90}, {Work1ID: 2, Work2ID: 1, Avg: 60, Max: 70}},
+			avg:     [][]float64{{100, 60, 0}, {60, 100, 0}, {0, 0, 100}},
+			max:     [][]float64{{100, 90, 0}, {90, 100, 0}, {0, 0, 100}},
+		},
+		{
+			name: "unknown and reference works ignored",
+			reports: []ReportItem{
+				{Work1ID: 1, Work2ID: 7, Avg: 40, Max: 50},
+				{Work1ID: 2, Work2ID: 0, Avg: 40, Max: 50, Work2Corpus: "books"},
+				{Work1ID: 2, Work2ID: 2, Avg: 40, Max: 50},
+			},
+			avg: [][]float64{{100, 0, 0}, {0, 100, 0}, {0, 0, 100}},
+			max: [][]float64{{100, 0, 0}, {0, 100, 0}, {0, 0, 100}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newSimilarityMatrix(5, []WorkEntry{{Id: 1}, {Id: 2}, {Id: 3}})
+			m.add(tt.reports)
+			if !reflect.DeepEqual(m.Avg, tt.avg) || !reflect.DeepEqual(m.Max, tt.max) {
+				t.Errorf("got avg %v and max %v, want %v and %v", m.Avg, m.Max, tt.avg, tt.max)
+			}
+		})
+	}
+}
+
+func TestProcessSweep(t *testing.T) {
+	appLogger := &logger.Logger{Logger: *logrus.New()}
+	appLogger.SetOutput(io.Discard)
+
+	service := &stubService{
+		task:  NewTaskDTO{EventID: 5, Type: TaskTypeSweep},
+		works: []WorkEntry{testWork(3), testWork(1), testWork(4), testWork(2)},
+		base:  []WorkEntry{testWork(4)},
+		results: map[uint64][]ReportItem{
+			3: {{Work1ID: 3, Work2ID: 1, Avg: 40, Max: 50}},
+		},
+	}
+	stub := &stubChecker{}
+	NewHandler(appLogger, service, stub, HandlerOptions{}).Process()
+
+	wantPairs := [][2]uint64{{2, 1}, {3, 1}, {3, 2}}
+	if !reflect.DeepEqual(stub.pairs, wantPairs) {
+		t.Errorf("checked pairs %v, want %v", stub.pairs, wantPairs)
+	}
+	if len(service.sent) != 1 || service.sent[0].Work1ID != 3 || service.sent[0].Work2ID != 1 {
+		t.Errorf("sent reports %+v, want the report of works 3 and 1", service.sent)
+	}
+
+	want := MatrixDTO{
+		EventID: 5,
+		Works:   []uint64{1, 2, 3},
+		Avg:     [][]float64{{100, 0, 40}, {0, 100, 0}, {40, 0, 100}},
+		Max:     [][]float64{{100, 0, 50}, {0, 100, 0}, {50, 0, 100}},
+	}
+	if !reflect.DeepEqual(service.matrix, want) {
+		t.Errorf("sent matrix %+v, want %+v", service.matrix, want)
+	}
+}
@@ -0,0 +1,142 @@
+package tokenize
+
+// Texts of the abstract vocabulary shared by all languages.
+// Tokens are mapped to what they do, so a program and its translation
+// to another language give similar streams.
+const (
+	AbstractBranch   = "BRANCH"   // Conditions and switches.
+	AbstractLoop     = "LOOP"     // Loops of any kind.
+	AbstractJump     = "JUMP"     // break, continue and goto.
+	AbstractReturn   = "RETURN"   // return and yield.
+	AbstractTry      = "TRY"      // Handling of exceptions.
+	AbstractThrow    = "THROW"    // Raising of exceptions.
+	AbstractType     = "TYPE"     // Declarations of classes, structures and interfaces.
+	AbstractFunction = "FUNCTION" // Declarations of functions and methods.
+	AbstractCall     = "CALL"     // Calls of functions, methods and constructors.
+	AbstractName     = "NAME"     // Variables, fields and parameters.
+	AbstractLiteral  = "LITERAL"
+	AbstractAssign   = "ASSIGN"
+	AbstractArith    = "ARITH"
+	AbstractCompare  = "COMPARE"
+	AbstractLogic    = "LOGIC"
+	AbstractIndex    = "INDEX"
+)
+
+// Keywords with their abstract texts. Keywords, which are missing, are dropped:
+// modifiers, type names and words without counterparts in other languages.
+var abstractKeywords = map[string]string{
+	"if": AbstractBranch, "else": AbstractBranch, "elif": AbstractBranch, "switch": AbstractBranch,
+	"case": AbstractBranch, "match": AbstractBranch, "select": AbstractBranch,
+	"for": AbstractLoop, "foreach": AbstractLoop, "while": AbstractLoop, "do": AbstractLoop,
+	"break": AbstractJump, "continue": AbstractJump, "goto": AbstractJump, "fallthrough": AbstractJump,
+	"return": AbstractReturn, "yield": AbstractReturn, "co_return": AbstractReturn, "co_yield": AbstractReturn,
+	"try": AbstractTry, "catch": AbstractTry, "except": AbstractTry, "finally": AbstractTry, "defer": AbstractTry,
+	"throw": AbstractThrow, "raise": AbstractThrow,
+	"class": AbstractType, "struct": AbstractType, "interface": AbstractType, "enum": AbstractType,
+	"record": AbstractType, "union": AbstractType,
+	"true": AbstractLiteral, "false": AbstractLiteral, "null": AbstractLiteral, "nullptr": AbstractLiteral,
+	"True": AbstractLiteral, "False": AbstractLiteral, "None": AbstractLiteral,
+	"and": AbstractLogic, "or": AbstractLogic, "not": AbstractLogic,
+	"is": AbstractCompare, "instanceof": AbstractCompare,
+	"sizeof": AbstractCall, "typeof": AbstractCall, "nameof": AbstractCall,
+}
+
+// Operators with their abstract texts. Punctuation, which is missing, is dropped.
+var abstractOperators = map[string]string{
+	"=": AbstractAssign, ":=": AbstractAssign, "+=": AbstractAssign, "-=": AbstractAssign,
+	"*=": AbstractAssign, "/=": AbstractAssign, "%=": AbstractAssign, "&=": AbstractAssign,
+	"|=": AbstractAssign, "^=": AbstractAssign, "<<=": AbstractAssign, ">>=": AbstractAssign,
+	"**=": AbstractAssign, "//=": AbstractAssign, "??=": AbstractAssign, "&^=": AbstractAssign,
+	"++": AbstractAssign, "--": AbstractAssign,
+	"+": AbstractArith, "-": AbstractArith, "*": AbstractArith, "/": AbstractArith, "%": AbstractArith,
+	"**": AbstractArith, "//": AbstractArith, "<<": AbstractArith, ">>": AbstractArith,
+	"&": AbstractArith, "|": AbstractArith, "^": AbstractArith, "~": AbstractArith, "&^": AbstractArith,
+	"==": AbstractCompare, "!=": AbstractCompare, "<": AbstractCompare, ">": AbstractCompare,
+	"<=": AbstractCompare, ">=": AbstractCompare, "<=>": AbstractCompare,
+	"&&": AbstractLogic, "||": AbstractLogic, "!": AbstractLogic, "??": AbstractLogic,
+	"?": AbstractBranch,
+	"[": AbstractIndex,
+}
+
+// Abstract maps tokens of any language to the abstract vocabulary.
+// Qualifiers, "self", types of declarations, empty brackets of array types and annotations are dropped,
+// so "System.out.println(x)" and "print(x)" or "String s = x" and "s = x" give the same stream.
+// Positions of tokens are kept.
+func Abstract(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+	at := func(i int) Token {
+		if 0 <= i && i < len(tokens) {
+			return tokens[i]
+		}
+		return Token{}
+	}
+
+	for i, token := range tokens {
+		next := at(i + 1)
+		text := ""
+
+		switch token.Kind {
+		case Identifier:
+			switch {
+			case at(i-1).Raw == "@", next.Raw == ".", next.Raw == "::", token.Raw == "self",
+				next.Kind == Identifier && next.Line == token.Line:
+				// Annotations, qualifiers, the receiver of Python methods and types of declarations.
+			case next.Raw == "(" && isFunctionDeclaration(tokens, i):
+				text = AbstractFunction
+			case next.Raw == "(":
+				text = AbstractCall
+			default:
+				text = AbstractName
+			}
+		case Number, String, Char:
+			text = AbstractLiteral
+		case Keyword:
+			if token.Raw == "else" && next.Raw == "if" {
+				// "else if" is a single branch as "elif".
+				break
+			}
+			text = abstractKeywords[token.Raw]
+		case Operator:
+			if token.Raw == "[" && next.Raw == "]" {
+				break
+			}
+			text = abstractOperators[token.Raw]
+		}
+
+		if text != "" {
+			token.Text = text
+			result = append(result, token)
+		}
+	}
+
+	return result
+}
+
+// isFunctionDeclaration reports, whether the name before the parenthesis is declared:
+// it follows a keyword of functions or the parameters are followed by a body.
+func isFunctionDeclaration(tokens []Token, name int) bool {
+	if name > 0 {
+		switch tokens[name-1].Raw {
+		case "def", "func", "fn", "function":
+			return true
+		}
+	}
+
+	depth := 0
+	for i := name + 1; i < len(tokens); i++ {
+		switch tokens[i].Raw {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				if i+1 >= len(tokens) {
+					return false
+				}
+				next := tokens[i+1].Raw
+				return next == "{" || next == "throws" || next == "const" || next == "override" || next == "noexcept"
+			}
+		}
+	}
+	return false
+}
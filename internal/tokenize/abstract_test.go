@@ -0,0 +1,88 @@
+package tokenize
+
+import "testing"
+
+func TestAbstractTranslations(t *testing.T) {
+	java := "class Main {\n" +
+		"  static int sum(int[] a) {\n" +
+		"    int s = 0;\n" +
+		"    for (int x : a) {\n" +
+		"      if (x > 0) {\n" +
+		"        s += x;\n" +
+		"      }\n" +
+		"    }\n" +
+		"    System.out.println(s);\n" +
+		"    return s;\n" +
+		"  }\n" +
+		"}\n"
+	translations := []struct {
+		language string
+		src      string
+	}{
+		{"csharp", "class Program {\n" +
+			"  static int Sum(int[] a) {\n" +
+			"    int s = 0;\n" +
+			"    foreach (int x in a) {\n" +
+			"      if (x > 0) {\n" +
+			"        s += x;\n" +
+			"      }\n" +
+			"    }\n" +
+			"    Console.WriteLine(s);\n" +
+			"    return s;\n" +
+			"  }\n" +
+			"}\n"},
+		{"python", "class Main:\n" +
+			"  def sum(self, a):\n" +
+			"    s = 0\n" +
+			"    for x in a:\n" +
+			"      if x > 0:\n" +
+			"        s += x\n" +
+			"    print(s)\n" +
+			"    return s\n"},
+	}
+
+	want := texts(Abstract(Java([]byte(java), Options{})))
+	for _, tt := range translations {
+		t.Run(tt.language, func(t *testing.T) {
+			language, _ := ForLanguage(tt.language)
+			if got := texts(Abstract(language.Lexer([]byte(tt.src), Options{}))); got != want {
+				t.Errorf("got %q, want %q of Java", got, want)
+			}
+		})
+	}
+}
+
+func TestAbstract(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		want     string
+	}{
+		{"qualified call", "java", "System.out.println(x);", "CALL NAME"},
+		{"declared type", "java", "String s = x;", "NAME ASSIGN NAME"},
+		{"else if", "csharp", "if (a) {} else if (b) {}", "BRANCH NAME BRANCH NAME"},
+		{"elif", "python", "if a:\n  pass\nelif b:\n  pass\n", "BRANCH NAME BRANCH NAME"},
+		{"annotation", "java", "@Override\nvoid run() {}", "FUNCTION"},
+		{"array type", "csharp", "int[] a = new int[n];", "NAME ASSIGN INDEX NAME"},
+		{"go function", "go", "func f(a int) int { return a * 2 }", "FUNCTION NAME NAME RETURN NAME ARITH LITERAL"},
+		{"exceptions", "python", "try:\n  raise E()\nexcept E:\n  pass\n", "TRY THROW CALL TRY NAME"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			language, _ := ForLanguage(tt.language)
+			if got := texts(Abstract(language.Lexer([]byte(tt.src), Options{}))); got != tt.want {
+				t.Errorf("Abstract(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAbstractPositions(t *testing.T) {
+	tokens := Abstract(Python([]byte("x = 1\nprint(x)\n"), Options{}))
+	call := tokens[3]
+	if call.Text != AbstractCall || call.Raw != "print" || call.Line != 2 || call.Column != 1 {
+		t.Errorf("got %+v, want the call of print at 2:1", call)
+	}
+}
@@ -0,0 +1,41 @@
+package tokenize
+
+var cFamilySyntax = &cLikeSyntax{
+	keywords: toSet(
+		// C.
+		"auto", "break", "case", "char", "const", "continue", "default", "do", "double", "else",
+		"enum", "extern", "float", "for", "goto", "if", "inline", "int", "long", "register",
+		"restrict", "return", "short", "signed", "sizeof", "static", "struct", "switch", "typedef", "union",
+		"unsigned", "void", "volatile", "while", "_Bool", "_Static_assert", "bool", "true", "false",
+		// C++.
+		"alignas", "alignof", "catch", "class", "constexpr", "consteval", "constinit", "const_cast", "decltype", "delete",
+		"dynamic_cast", "explicit", "export", "friend", "mutable", "namespace", "new", "noexcept", "nullptr", "operator",
+		"private", "protected", "public", "reinterpret_cast", "static_assert", "static_cast", "template", "this", "thread_local", "throw",
+		"try", "typeid", "typename", "using", "virtual", "wchar_t", "char8_t", "char16_t", "char32_t", "concept",
+		"requires", "co_await", "co_return", "co_yield",
+	),
+	// ">>" is never a token, because it closes nested templates.
+	operators: []string{
+		"<<=", "<=>", "->*", "...",
+		"->", "::", "==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=", "%=",
+		"&=", "|=", "^=", "<<", ".*", "##",
+	},
+	stringPrefixes: toSet("L", "u", "U", "u8"),
+	directives:     true,
+	rawStrings:     true,
+}
+
+// CFamily splits the C or C++ source into tokens.
+// Raw strings are single literals, directives are single tokens.
+// Includes, using namespace directives and other preprocessor directives are dropped,
+// unless options keep them.
+func CFamily(src []byte, options Options) []Token {
+	tokens := scanCLike(src, options, cFamilySyntax)
+
+	if options.KeepImports {
+		return tokens
+	}
+	return dropStatements(tokens, "using", func(next Token) bool {
+		return next.Kind == Keyword && next.Raw == "namespace"
+	})
+}
@@ -0,0 +1,189 @@
+package tokenize
+
+// cLikeSyntax describes a language with C comments, quoted strings and characters.
+type cLikeSyntax struct {
+	keywords  map[string]struct{}
+	operators []string // From the longest.
+
+	stringPrefixes map[string]struct{} // Words, which make the next quoted literal a string or a character.
+	directives     bool                // "#" at the start of a line begins a preprocessor directive.
+	rawStrings     bool                // C++ raw strings R"delim(...)delim".
+	textBlocks     bool                // Java text blocks """...""".
+	backquoted     bool                // Go raw strings `...`.
+}
+
+type cLikeLexer struct {
+	*scanner
+	syntax    *cLikeSyntax
+	lineStart bool // Only whitespace is before the position on its line.
+}
+
+// scanCLike splits the source of the language into tokens.
+func scanCLike(src []byte, options Options, syntax *cLikeSyntax) []Token {
+	l := &cLikeLexer{scanner: newScanner(src, options), syntax: syntax, lineStart: true}
+
+	for {
+		if l.skipSpace() {
+			l.lineStart = true
+		}
+		if l.eof() {
+			return l.tokens
+		}
+
+		c := l.peek(0)
+		l.begin()
+		switch {
+		case c == '/' && l.peek(1) == '/':
+			l.skipLine()
+			continue
+		case c == '/' && l.peek(1) == '*':
+			l.skipUntil("*/")
+			continue
+		case c == '#' && l.lineStart && syntax.directives:
+			l.scanDirective()
+			continue
+		case c == '"' && syntax.textBlocks && l.hasPrefix(`"""`):
+			l.advanceN(3)
+			l.skipUntilUnescaped(`"""`)
+			l.emit(String)
+		case c == '"':
+			l.scanQuoted('"')
+			l.emit(String)
+		case c == '`' && syntax.backquoted:
+			l.advance()
+			l.skipUntil("`")
+			l.emit(String)
+		case c == '\'':
+			l.scanQuoted('\'')
+			l.emit(Char)
+		case isDigit(c) || c == '.' && isDigit(l.peek(1)):
+			l.scanNumber()
+			l.emit(Number)
+		case isWordStart(l.peekRune()):
+			word := l.scanWord()
+			if l.scanPrefixed(word) {
+				break
+			}
+			if _, ok := syntax.keywords[word]; ok {
+				l.emit(Keyword)
+			} else {
+				l.emit(Identifier)
+			}
+		default:
+			l.scanOperator(syntax.operators)
+			l.emit(Operator)
+		}
+		l.lineStart = false
+	}
+}
+
+// scanPrefixed scans the literal after the prefix word, if the word is a prefix of it.
+func (l *cLikeLexer) scanPrefixed(word string) bool {
+	c := l.peek(0)
+	if c != '"' && c != '\'' {
+		return false
+	}
+
+	if l.syntax.rawStrings && c == '"' && len(word) > 0 && word[len(word)-1] == 'R' {
+		if _, ok := l.syntax.stringPrefixes[word[:len(word)-1]]; ok || word == "R" {
+			l.scanRawString()
+			l.emit(String)
+			return true
+		}
+	}
+	if _, ok := l.syntax.stringPrefixes[word]; !ok {
+		return false
+	}
+
+	l.scanQuoted(c)
+	if c == '"' {
+		l.emit(String)
+	} else {
+		l.emit(Char)
+	}
+	return true
+}
+
+// scanRawString scans the C++ raw string: R"delim( is closed by )delim".
+func (l *cLikeLexer) scanRawString() {
+	l.advance()
+	delim := ""
+	for !l.eof() && l.peek(0) != '(' && l.peek(0) != '\n' {
+		delim += string(l.peek(0))
+		l.advance()
+	}
+	l.skipUntil(")" + delim + `"`)
+}
+
+// scanDirective scans the preprocessor directive, which continues after escaped line breaks.
+// Includes are imports, other directives are kept by their own option.
+func (l *cLikeLexer) scanDirective() {
+	for !l.eof() && l.peek(0) != '\n' {
+		switch {
+		case l.peek(0) == '\\' && l.peek(1) == '\n':
+			l.advanceN(2)
+		case l.hasPrefix("/*"):
+			l.skipUntil("*/")
+		default:
+			l.advance()
+		}
+	}
+
+	keep := l.options.KeepDirectives
+	if isIncludeDirective(l.text()) {
+		keep = l.options.KeepImports
+	}
+	if keep {
+		l.emit(Directive)
+	}
+}
+
+// skipUntilUnescaped moves the position after the end marker, which is not escaped by a backslash.
+func (l *cLikeLexer) skipUntilUnescaped(end string) {
+	for !l.eof() && !l.hasPrefix(end) {
+		if l.peek(0) == '\\' {
+			l.advance()
+		}
+		l.advance()
+	}
+	l.advanceN(len(end))
+}
+
+// isIncludeDirective reports, whether the directive includes or imports a file.
+func isIncludeDirective(directive string) bool {
+	i := 1
+	for i < len(directive) && (directive[i] == ' ' || directive[i] == '\t') {
+		i++
+	}
+	name := directive[i:]
+	for _, include := range []string{"include", "import", "include_next"} {
+		if len(name) >= len(include) && name[:len(include)] == include &&
+			(len(name) == len(include) || !isWordRune(rune(name[len(include)]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropStatements removes statements, which begin with the keyword, up to the semicolon.
+// The keyword must be the first token of the statement, follow filters the token after it.
+func dropStatements(tokens []Token, keyword string, follow func(next Token) bool) []Token {
+	result := make([]Token, 0, len(tokens))
+	statementStart := true
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if statementStart && token.Kind == Keyword && token.Raw == keyword &&
+			i+1 < len(tokens) && (follow == nil || follow(tokens[i+1])) {
+			for i < len(tokens) && tokens[i].Raw != ";" {
+				i++
+			}
+			continue
+		}
+
+		statementStart = token.Kind == Operator && (token.Raw == ";" || token.Raw == "{" || token.Raw == "}")
+		result = append(result, token)
+	}
+
+	return result
+}
@@ -0,0 +1,291 @@
+package tokenize
+
+var csharpKeywords = toSet(
+	"abstract", "as", "base", "bool", "break", "byte", "case", "catch", "char", "checked",
+	"class", "const", "continue", "decimal", "default", "delegate", "do", "double", "else", "enum",
+	"event", "explicit", "extern", "false", "finally", "fixed", "float", "for", "foreach", "goto",
+	"if", "implicit", "in", "int", "interface", "internal", "is", "lock", "long", "namespace",
+	"new", "null", "object", "operator", "out", "override", "params", "private", "protected", "public",
+	"readonly", "ref", "return", "sbyte", "sealed", "short", "sizeof", "stackalloc", "static", "string",
+	"struct", "switch", "this", "throw", "true", "try", "typeof", "uint", "ulong", "unchecked",
+	"unsafe", "ushort", "using", "virtual", "void", "volatile", "while",
+	// Contextual keywords, which are rarely names.
+	"var", "dynamic", "async", "await", "yield", "get", "set", "init", "nameof", "when",
+	"where", "partial", "record", "global", "required", "nint", "nuint", "unmanaged",
+)
+
+// Operators of C# from the longest. ">>" is never a token, because it closes nested generics.
+var csharpOperators = []string{
+	"<<=", "??=",
+	"->", "=>", "==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=", "%=",
+	"&=", "|=", "^=", "<<", "??", "?.", "::", "..",
+}
+
+type csharpLexer struct {
+	*scanner
+	lineStart bool // Only whitespace is before the position on its line.
+}
+
+// CSharp splits the C# source into tokens.
+// Verbatim, interpolated and raw strings are single literals followed by tokens of their holes.
+// Preprocessor directives and using directives are dropped, unless options keep them.
+func CSharp(src []byte, options Options) []Token {
+	l := &csharpLexer{scanner: newScanner(src, options), lineStart: true}
+	l.scan(false)
+
+	if options.KeepImports {
+		return l.tokens
+	}
+	return dropCSharpUsings(l.tokens)
+}
+
+// scan scans tokens up to the end of the source or of the interpolation hole.
+func (l *csharpLexer) scan(inHole bool) {
+	depth := 0 // Brackets opened inside the hole.
+
+	for {
+		if l.skipSpace() {
+			l.lineStart = true
+		}
+		if l.eof() {
+			return
+		}
+
+		c := l.peek(0)
+		if inHole && depth == 0 && (c == '}' || c == ':' && l.peek(1) != ':') {
+			return
+		}
+
+		l.begin()
+		switch {
+		case c == '/' && l.peek(1) == '/':
+			l.skipLine()
+			continue
+		case c == '/' && l.peek(1) == '*':
+			l.skipUntil("*/")
+			continue
+		case c == '#' && l.lineStart && !inHole:
+			l.skipLine()
+			if l.options.KeepDirectives {
+				l.emit(Directive)
+			}
+			continue
+		case l.scanString():
+		case c == '\'':
+			l.scanQuoted('\'')
+			l.emit(Char)
+		case isDigit(c) || c == '.' && isDigit(l.peek(1)):
+			l.scanNumber()
+			l.emit(Number)
+		case c == '@' && isWordStart(rune(l.peek(1))):
+			l.advance()
+			l.scanWord()
+			l.emit(Identifier)
+		case isWordStart(l.peekRune()):
+			if _, ok := csharpKeywords[l.scanWord()]; ok {
+				l.emit(Keyword)
+			} else {
+				l.emit(Identifier)
+			}
+		default:
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			}
+			l.scanOperator(csharpOperators)
+			l.emit(Operator)
+		}
+		l.lineStart = false
+	}
+}
+
+// scanString scans a string literal of any kind and its holes, if it is at the position.
+func (l *csharpLexer) scanString() bool {
+	i, dollars, verbatim := 0, 0, false
+	for {
+		if c := l.peek(i); c == '$' {
+			dollars++
+		} else if c == '@' && !verbatim {
+			verbatim = true
+		} else {
+			break
+		}
+		i++
+	}
+	if l.peek(i) != '"' {
+		return false
+	}
+
+	quotes := 0
+	for l.peek(i+quotes) == '"' {
+		quotes++
+	}
+
+	// The literal goes before tokens of its holes, its text is known at the end.
+	index := len(l.tokens)
+	l.emit(String)
+	startPos := l.start
+
+	l.advanceN(i)
+	switch {
+	case quotes >= 3 && !verbatim:
+		l.scanRawString(quotes, dollars)
+	case verbatim:
+		l.scanVerbatimString(dollars > 0)
+	default:
+		l.scanRegularString(dollars > 0)
+	}
+
+	// UTF-8 string literal.
+	if l.hasPrefix("u8") || l.hasPrefix("U8") {
+		l.advanceN(2)
+	}
+
+	raw := string(l.src[startPos:l.pos])
+	l.tokens[index].Raw = raw
+	l.tokens[index].Text = normalizedText(String, raw, l.options)
+	return true
+}
+
+func (l *csharpLexer) scanRegularString(interpolated bool) {
+	l.advance()
+	for !l.eof() {
+		c := l.peek(0)
+		switch {
+		case c == '\\':
+			l.advanceN(2)
+		case c == '"':
+			l.advance()
+			return
+		case c == '\n':
+			return
+		case interpolated && c == '{' && l.peek(1) == '{':
+			l.advanceN(2)
+		case interpolated && c == '{':
+			l.advance()
+			l.scanHole(1)
+		default:
+			l.advance()
+		}
+	}
+}
+
+// scanVerbatimString scans the string, where quotes are escaped by doubling and lines may break.
+func (l *csharpLexer) scanVerbatimString(interpolated bool) {
+	l.advance()
+	for !l.eof() {
+		c := l.peek(0)
+		switch {
+		case c == '"' && l.peek(1) == '"':
+			l.advanceN(2)
+		case c == '"':
+			l.advance()
+			return
+		case interpolated && c == '{' && l.peek(1) == '{':
+			l.advanceN(2)
+		case interpolated && c == '{':
+			l.advance()
+			l.scanHole(1)
+		default:
+			l.advance()
+		}
+	}
+}
+
+// scanRawString scans the string closed by the same count of quotes as opened.
+// Holes of the interpolated one are opened by as many braces as there are dollars.
+func (l *csharpLexer) scanRawString(quotes int, dollars int) {
+	l.advanceN(quotes)
+	for !l.eof() {
+		c := l.peek(0)
+		switch {
+		case c == '"':
+			count := 0
+			for l.peek(count) == '"' {
+				count++
+			}
+			l.advanceN(count)
+			if count >= quotes {
+				return
+			}
+		case dollars > 0 && c == '{':
+			count := 0
+			for l.peek(count) == '{' {
+				count++
+			}
+			l.advanceN(count)
+			if count >= dollars {
+				l.scanHole(dollars)
+			}
+		default:
+			l.advance()
+		}
+	}
+}
+
+// scanHole scans tokens of the interpolation hole, its format and the closing braces.
+func (l *csharpLexer) scanHole(braces int) {
+	l.scan(true)
+
+	if l.peek(0) == ':' {
+		for !l.eof() && l.peek(0) != '}' && l.peek(0) != '"' {
+			l.advance()
+		}
+	}
+	for i := 0; i < braces && l.peek(0) == '}'; i++ {
+		l.advance()
+	}
+}
+
+// dropCSharpUsings removes using directives: usings outside of types and members,
+// which are not using statements.
+func dropCSharpUsings(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+	var namespaces []bool // Kinds of open braces: true - a namespace body.
+	header := false       // The namespace keyword is before the next brace.
+
+	topLevel := func() bool {
+		for _, isNamespace := range namespaces {
+			if !isNamespace {
+				return false
+			}
+		}
+		return true
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		if token.Kind == Keyword && topLevel() {
+			next := i + 1
+			if token.Raw == "global" && next < len(tokens) && tokens[next].Raw == "using" {
+				next++
+			}
+			isDirective := token.Raw == "using" || next != i+1
+			if isDirective && next < len(tokens) && tokens[next].Raw != "(" && tokens[next].Raw != "var" {
+				for i < len(tokens) && tokens[i].Raw != ";" {
+					i++
+				}
+				continue
+			}
+		}
+
+		switch {
+		case token.Kind == Keyword && token.Raw == "namespace":
+			header = true
+		case token.Kind == Operator && token.Raw == "{":
+			namespaces = append(namespaces, header)
+			header = false
+		case token.Kind == Operator && token.Raw == "}" && len(namespaces) > 0:
+			namespaces = namespaces[:len(namespaces)-1]
+		case token.Kind == Operator && token.Raw == ";":
+			header = false
+		}
+
+		result = append(result, token)
+	}
+
+	return result
+}
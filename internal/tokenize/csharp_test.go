@@ -0,0 +1,52 @@
+package tokenize
+
+import (
+	"strings"
+	"testing"
+)
+
+// texts joins normalized texts of tokens with spaces.
+func texts(tokens []Token) string {
+	parts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		parts = append(parts, token.Text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestCSharp(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"verbatim string", `s = @"a\""b";`, "ID = STR ;"},
+		{"verbatim string over lines", "s = @\"a\n}b\";", "ID = STR ;"},
+		{"interpolated string holes", `s = $"x{y + 1}z{w:0.00}";`, "ID = STR ID + NUM ID ;"},
+		{"interpolated verbatim string", `s = $@"p{q}\";`, "ID = STR ID ;"},
+		{"nested interpolation", `s = $"a{f($"{b}")}";`, "ID = STR ID ( STR ID ) ;"},
+		{"escaped braces", `s = $"{{a}}";`, "ID = STR ;"},
+		{"raw string", `s = """a "" b""";`, "ID = STR ;"},
+		{"interpolated raw string", `s = $$"""{a}{{b}}""";`, "ID = STR ID ;"},
+		{"global scope operator", `s = $"{global::A.B}";`, "ID = STR global :: ID . ID ;"},
+		{"usings dropped", "using System;\nusing static System.Math;\nusing X = System.Text;\nclass A {}", "class ID { }"},
+		{"using statement kept", "void F() { using (var r = G()) {} }", "void ID ( ) { using ( var ID = ID ( ) ) { } }"},
+		{"directives dropped", "#if DEBUG\nint x;\n#endif", "int ID ;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := texts(CSharp([]byte(tt.src), Options{})); got != tt.want {
+				t.Errorf("CSharp(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSharpPositions(t *testing.T) {
+	tokens := CSharp([]byte("s = @\"a\nb\" +\n  x;"), Options{})
+	x := tokens[len(tokens)-2]
+	if x.Raw != "x" || x.Line != 3 || x.Column != 3 {
+		t.Errorf("got %q at %d:%d, want \"x\" at 3:3", x.Raw, x.Line, x.Column)
+	}
+}
@@ -0,0 +1,56 @@
+package tokenize
+
+var goSyntax = &cLikeSyntax{
+	keywords: toSet(
+		"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for",
+		"func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return",
+		"select", "struct", "switch", "type", "var",
+	),
+	operators: []string{
+		"<<=", ">>=", "&^=", "...",
+		"&&", "||", "<-", "++", "--", "==", "!=", "<=", ">=", ":=", "<<", ">>", "&^",
+		"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	},
+	backquoted: true,
+}
+
+// Go splits the Go source into tokens.
+// Raw strings are single literals. Imports are dropped, unless options keep them.
+func Go(src []byte, options Options) []Token {
+	tokens := scanCLike(src, options, goSyntax)
+
+	if options.KeepImports {
+		return tokens
+	}
+	return dropGoImports(tokens)
+}
+
+// dropGoImports removes import declarations: single imports with an optional name
+// and groups in parentheses.
+func dropGoImports(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.Kind != Keyword || token.Raw != "import" {
+			result = append(result, token)
+			continue
+		}
+
+		if i+1 < len(tokens) && tokens[i+1].Raw == "(" {
+			for i < len(tokens) && tokens[i].Raw != ")" {
+				i++
+			}
+			continue
+		}
+		for i+1 < len(tokens) && tokens[i+1].Kind != String {
+			i++
+		}
+		i++
+		if i+1 < len(tokens) && tokens[i+1].Raw == ";" {
+			i++
+		}
+	}
+
+	return result
+}
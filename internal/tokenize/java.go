@@ -0,0 +1,32 @@
+package tokenize
+
+var javaSyntax = &cLikeSyntax{
+	keywords: toSet(
+		"abstract", "assert", "boolean", "break", "byte", "case", "catch", "char", "class", "const",
+		"continue", "default", "do", "double", "else", "enum", "extends", "final", "finally", "float",
+		"for", "goto", "if", "implements", "import", "instanceof", "int", "interface", "long", "native",
+		"new", "package", "private", "protected", "public", "return", "short", "static", "strictfp", "super",
+		"switch", "synchronized", "this", "throw", "throws", "transient", "try", "void", "volatile", "while",
+		"true", "false", "null",
+		// Contextual keywords, which are rarely names.
+		"var", "record", "yield", "sealed", "permits",
+	),
+	// ">>" and ">>>" are never tokens, because they close nested generics.
+	operators: []string{
+		"<<=", "...",
+		"->", "::", "==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=", "%=",
+		"&=", "|=", "^=", "<<",
+	},
+	textBlocks: true,
+}
+
+// Java splits the Java source into tokens.
+// Text blocks are single literals. Imports are dropped, unless options keep them.
+func Java(src []byte, options Options) []Token {
+	tokens := scanCLike(src, options, javaSyntax)
+
+	if options.KeepImports {
+		return tokens
+	}
+	return dropStatements(tokens, "import", nil)
+}
@@ -0,0 +1,196 @@
+package tokenize
+
+import "strings"
+
+var pythonKeywords = toSet(
+	"False", "None", "True", "and", "as", "assert", "async", "await", "break", "class",
+	"continue", "def", "del", "elif", "else", "except", "finally", "for", "from", "global",
+	"if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise",
+	"return", "try", "while", "with", "yield",
+)
+
+// Operators of Python from the longest.
+var pythonOperators = []string{
+	"**=", "//=", ">>=", "<<=", "...",
+	"->", ":=", "**", "//", "==", "!=", "<=", ">=", "<<", ">>", "+=", "-=", "*=", "/=", "%=",
+	"&=", "|=", "^=", "@=",
+}
+
+type pythonLexer struct {
+	*scanner
+}
+
+// Python splits the Python source into tokens.
+// Strings are single literals, f-strings are followed by tokens of their holes.
+// Indentation is not in the stream. Imports are dropped, unless options keep them.
+func Python(src []byte, options Options) []Token {
+	l := &pythonLexer{scanner: newScanner(src, options)}
+	l.scan(false)
+
+	if options.KeepImports {
+		return l.tokens
+	}
+	return dropPythonImports(l.tokens)
+}
+
+// scan scans tokens up to the end of the source or of the f-string hole.
+func (l *pythonLexer) scan(inHole bool) {
+	depth := 0 // Brackets opened inside the hole.
+
+	for {
+		l.skipSpace()
+		if l.eof() {
+			return
+		}
+
+		c := l.peek(0)
+		if inHole && depth == 0 && (c == '}' || c == ':' || c == '!' && l.peek(1) != '=') {
+			return
+		}
+
+		l.begin()
+		switch {
+		case c == '#':
+			l.skipLine()
+		case c == '\\' && l.peek(1) == '\n':
+			l.advanceN(2)
+		case l.scanString():
+		case isDigit(c) || c == '.' && isDigit(l.peek(1)):
+			l.scanNumber()
+			l.emit(Number)
+		case isWordStart(l.peekRune()):
+			if _, ok := pythonKeywords[l.scanWord()]; ok {
+				l.emit(Keyword)
+			} else {
+				l.emit(Identifier)
+			}
+		default:
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			}
+			l.scanOperator(pythonOperators)
+			l.emit(Operator)
+		}
+	}
+}
+
+// scanString scans a string literal with its prefix and the holes of the f-string,
+// if it is at the position.
+func (l *pythonLexer) scanString() bool {
+	i := 0
+	for i < 2 && strings.IndexByte("rRbBuUfF", l.peek(i)) >= 0 {
+		i++
+	}
+	quote := l.peek(i)
+	if quote != '"' && quote != '\'' {
+		return false
+	}
+	formatted := strings.ContainsAny(string(l.src[l.pos:l.pos+i]), "fF")
+
+	// The literal goes before tokens of its holes, its text is known at the end.
+	index := len(l.tokens)
+	l.emit(String)
+	startPos := l.start
+
+	l.advanceN(i)
+	end := string(quote)
+	if l.peek(1) == quote && l.peek(2) == quote {
+		end = strings.Repeat(end, 3)
+	}
+	l.advanceN(len(end))
+
+	// Backslashes escape quotes in raw strings too, so they are skipped in any string.
+	for !l.eof() && !l.hasPrefix(end) {
+		c := l.peek(0)
+		if c == '\n' && len(end) == 1 {
+			// Unterminated literals end at the line break.
+			end = ""
+			break
+		}
+		switch {
+		case c == '\\':
+			l.advanceN(2)
+		case formatted && c == '{' && l.peek(1) == '{':
+			l.advanceN(2)
+		case formatted && c == '{':
+			l.advance()
+			l.scanHole()
+		default:
+			l.advance()
+		}
+	}
+	l.advanceN(len(end))
+
+	text := string(l.src[startPos:l.pos])
+	l.tokens[index].Raw = text
+	l.tokens[index].Text = normalizedText(String, text, l.options)
+	return true
+}
+
+// scanHole scans tokens of the f-string hole, its conversion, format and the closing brace.
+func (l *pythonLexer) scanHole() {
+	l.scan(true)
+
+	for !l.eof() && l.peek(0) != '}' && l.peek(0) != '\n' {
+		if l.peek(0) == '{' {
+			// Nested holes of the format.
+			l.advance()
+			l.scanHole()
+			continue
+		}
+		l.advance()
+	}
+	if l.peek(0) == '}' {
+		l.advance()
+	}
+}
+
+// dropPythonImports removes import statements: "import" and "from ... import" at the start
+// of a statement up to the end of its line or of the parenthesized list of names.
+func dropPythonImports(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+	depth := 0    // Brackets open before the token.
+	lastLine := 0 // The line, where the previous token ends.
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		statementStart := depth == 0 && (token.Line > lastLine || i > 0 && tokens[i-1].Raw == ";")
+
+		if statementStart && token.Kind == Keyword && (token.Raw == "import" || token.Raw == "from") {
+			line, names := token.Line, 0
+			for i+1 < len(tokens) {
+				next := tokens[i+1]
+				if names == 0 && next.Line != line {
+					break
+				}
+				if names == 0 && next.Raw == ";" {
+					i++
+					break
+				}
+				switch next.Raw {
+				case "(":
+					names++
+				case ")":
+					names--
+				}
+				i++
+			}
+			lastLine = tokens[i].Line
+			continue
+		}
+
+		switch token.Raw {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+		lastLine = token.Line + strings.Count(token.Raw, "\n")
+		result = append(result, token)
+	}
+
+	return result
+}
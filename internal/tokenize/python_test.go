@@ -0,0 +1,29 @@
+package tokenize
+
+import "testing"
+
+func TestPython(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"f-string holes", `x = f"a{y + 1}b"`, "ID = STR ID + NUM"},
+		{"conversion and format spec", `x = f"{z!r:>{w}}"`, "ID = STR ID ID"},
+		{"escaped braces", `x = f"{{a}}"`, "ID = STR"},
+		{"raw f-string", `x = rf'{q}\d'`, "ID = STR ID"},
+		{"dict in hole", `x = f"{d['k']}"`, "ID = STR ID [ STR ]"},
+		{"triple-quoted f-string", "x = f'''a\n{y}'''", "ID = STR ID"},
+		{"bytes", `x = b'{y}'`, "ID = STR"},
+		{"imports dropped", "import os\nfrom a.b import (c,\n  d)\nx = 1", "ID = NUM"},
+		{"import inside block dropped", "def f():\n    import os\n    return 1", "def ID ( ) : return NUM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := texts(Python([]byte(tt.src), Options{})); got != tt.want {
+				t.Errorf("Python(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
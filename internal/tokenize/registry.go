@@ -0,0 +1,72 @@
+package tokenize
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Lexer splits the source of a language into tokens.
+type Lexer func(src []byte, options Options) []Token
+
+// Language is a language supported by tokenizers.
+type Language struct {
+	Name       string
+	Extensions []string // Lowercase extensions of source files with the dot.
+	Lexer      Lexer
+	Indented   bool // Blocks are made by indentation, which is not in the token stream.
+}
+
+var languages = map[string]Language{}
+var extensions = map[string]string{} // Extension -> language name.
+
+func init() {
+	Register(Language{Name: "csharp", Extensions: []string{".cs"}, Lexer: CSharp})
+	Register(Language{Name: "java", Extensions: []string{".java"}, Lexer: Java})
+	Register(Language{Name: "python", Extensions: []string{".py", ".pyw"}, Lexer: Python, Indented: true})
+	Register(Language{Name: "cpp", Extensions: []string{".c", ".h", ".cpp", ".hpp", ".cc", ".hh", ".cxx", ".hxx"}, Lexer: CFamily})
+	Register(Language{Name: "go", Extensions: []string{".go"}, Lexer: Go})
+}
+
+// Register adds the language or replaces the one with the same name.
+// Extensions of the language take precedence over the same ones of registered languages.
+func Register(language Language) {
+	languages[language.Name] = language
+	for _, ext := range language.Extensions {
+		extensions[strings.ToLower(ext)] = language.Name
+	}
+}
+
+// Languages returns sorted names of supported languages.
+func Languages() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForLanguage returns the language by its name.
+func ForLanguage(name string) (Language, bool) {
+	language, ok := languages[name]
+	return language, ok
+}
+
+// ForFile returns the language of the file by its extension.
+func ForFile(name string) (Language, bool) {
+	language, ok := extensions[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return Language{}, false
+	}
+	return ForLanguage(language)
+}
+
+// Select returns the language of the file by its extension or the default language,
+// if the extension is unknown.
+func Select(name string, defaultLanguage string) (Language, bool) {
+	if language, ok := ForFile(name); ok {
+		return language, true
+	}
+	return ForLanguage(defaultLanguage)
+}
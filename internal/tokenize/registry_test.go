@@ -0,0 +1,56 @@
+package tokenize
+
+import "testing"
+
+func TestImports(t *testing.T) {
+	tests := []struct {
+		language string
+		src      string
+		dropped  string
+		kept     string
+	}{
+		{"java", "package a.b;\nimport java.util.List;\nimport static x.Y.z;\nclass A {}",
+			"package ID . ID ; class ID { }",
+			"package ID . ID ; import ID . ID . ID ; import static ID . ID . ID ; class ID { }"},
+		{"go", "package a\nimport (\n\t\"fmt\"\n\tx \"os\"\n)\nimport \"io\"\nvar v int",
+			"package ID var ID ID",
+			"package ID import ( STR ID STR ) import STR var ID ID"},
+		{"cpp", "#include <a.h>\n#include \"b.h\"\nint x;",
+			"int ID ;",
+			"#include <a.h> #include \"b.h\" int ID ;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			language, ok := ForLanguage(tt.language)
+			if !ok {
+				t.Fatalf("language %q is not registered", tt.language)
+			}
+			if got := texts(language.Lexer([]byte(tt.src), Options{})); got != tt.dropped {
+				t.Errorf("got %q, want %q", got, tt.dropped)
+			}
+			if got := texts(language.Lexer([]byte(tt.src), Options{KeepImports: true})); got != tt.kept {
+				t.Errorf("with imports got %q, want %q", got, tt.kept)
+			}
+		})
+	}
+}
+
+func TestForFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"a.CS", "csharp"},
+		{"dir/a.pyw", "python"},
+		{"a.hpp", "cpp"},
+		{"a.txt", ""},
+	}
+
+	for _, tt := range tests {
+		language, _ := ForFile(tt.file)
+		if language.Name != tt.want {
+			t.Errorf("ForFile(%q) = %q, want %q", tt.file, language.Name, tt.want)
+		}
+	}
+}
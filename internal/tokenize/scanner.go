@@ -0,0 +1,210 @@
+package tokenize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// scanner walks the source and tracks positions of characters.
+// Lexers of languages build tokens on top of it.
+type scanner struct {
+	src     []byte
+	pos     int
+	line    int
+	col     int
+	options Options
+	tokens  []Token
+
+	start     int // Position of the token being scanned.
+	startLine int
+	startCol  int
+}
+
+func newScanner(src []byte, options Options) *scanner {
+	return &scanner{src: src, line: 1, col: 1, options: options}
+}
+
+func (s *scanner) eof() bool {
+	return s.pos >= len(s.src)
+}
+
+// peek returns the byte at the offset from the position or 0 after the end.
+func (s *scanner) peek(offset int) byte {
+	if s.pos+offset < len(s.src) {
+		return s.src[s.pos+offset]
+	}
+	return 0
+}
+
+// peekRune returns the character at the position.
+func (s *scanner) peekRune() rune {
+	r, _ := utf8.DecodeRune(s.src[s.pos:])
+	return r
+}
+
+func (s *scanner) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(s.src[s.pos:min(len(s.src), s.pos+len(prefix))]), prefix)
+}
+
+// advance moves the position by one character.
+func (s *scanner) advance() {
+	if s.eof() {
+		return
+	}
+	if s.src[s.pos] == '\n' {
+		s.line++
+		s.col = 1
+		s.pos++
+		return
+	}
+	_, size := utf8.DecodeRune(s.src[s.pos:])
+	s.pos += size
+	s.col++
+}
+
+func (s *scanner) advanceN(n int) {
+	for i := 0; i < n; i++ {
+		s.advance()
+	}
+}
+
+// skipLine moves the position to the end of the line.
+func (s *scanner) skipLine() {
+	for !s.eof() && s.src[s.pos] != '\n' {
+		s.advance()
+	}
+}
+
+// skipUntil moves the position after the end marker or to the end of the source.
+func (s *scanner) skipUntil(end string) {
+	for !s.eof() && !s.hasPrefix(end) {
+		s.advance()
+	}
+	s.advanceN(len(end))
+}
+
+// skipSpace skips whitespace and reports, whether a line break was skipped.
+func (s *scanner) skipSpace() bool {
+	newLine := false
+	for !s.eof() {
+		r := s.peekRune()
+		if !unicode.IsSpace(r) {
+			break
+		}
+		newLine = newLine || r == '\n'
+		s.advance()
+	}
+	return newLine
+}
+
+// begin marks the start of the next token.
+func (s *scanner) begin() {
+	s.start, s.startLine, s.startCol = s.pos, s.line, s.col
+}
+
+// text returns the source of the token being scanned.
+func (s *scanner) text() string {
+	return string(s.src[s.start:s.pos])
+}
+
+// emit adds the scanned token to the stream.
+func (s *scanner) emit(kind Kind) {
+	raw := s.text()
+	s.tokens = append(s.tokens, Token{
+		Kind:   kind,
+		Text:   normalizedText(kind, raw, s.options),
+		Raw:    raw,
+		Line:   s.startLine,
+		Column: s.startCol,
+	})
+}
+
+// scanWord scans an identifier or a keyword.
+func (s *scanner) scanWord() string {
+	for !s.eof() && isWordRune(s.peekRune()) {
+		s.advance()
+	}
+	return s.text()
+}
+
+// scanQuoted scans the literal up to the closing quote, backslash escapes the next character.
+// Unterminated literals end at the line break.
+func (s *scanner) scanQuoted(quote byte) {
+	s.advance()
+	for !s.eof() {
+		switch s.peek(0) {
+		case '\\':
+			s.advanceN(2)
+		case quote:
+			s.advance()
+			return
+		case '\n':
+			return
+		default:
+			s.advance()
+		}
+	}
+}
+
+// scanNumber scans a numeric literal with prefixes, separators, exponents and suffixes.
+func (s *scanner) scanNumber() {
+	exponents := "eE"
+	if s.peek(0) == '0' && strings.ContainsRune("xXbBoO", rune(s.peek(1))) {
+		// Hex digits include "e", so only binary exponents of hex floats are signed.
+		if s.peek(1) == 'x' || s.peek(1) == 'X' {
+			exponents = "pP"
+		}
+		s.advanceN(2)
+	}
+	for !s.eof() {
+		c := s.peek(0)
+		switch {
+		case isDigit(c) || isLetter(c) || c == '_' || c == '\'':
+			if strings.IndexByte(exponents, c) >= 0 && (s.peek(1) == '+' || s.peek(1) == '-') {
+				s.advance()
+			}
+			s.advance()
+		case c == '.' && isDigit(s.peek(1)):
+			s.advance()
+		default:
+			return
+		}
+	}
+}
+
+// scanOperator scans the longest operator from the list or a single character.
+func (s *scanner) scanOperator(operators []string) {
+	for _, op := range operators {
+		if s.hasPrefix(op) {
+			s.advanceN(len(op))
+			return
+		}
+	}
+	s.advance()
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isWordStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// toSet makes a set of words.
+func toSet(words ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,75 @@
+package tokenize
+
+// Kind is the class of a token.
+type Kind uint8
+
+const (
+	Keyword Kind = iota + 1
+	Identifier
+	Number
+	String
+	Char
+	Operator // Operators and punctuation.
+	Directive
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Keyword:
+		return "keyword"
+	case Identifier:
+		return "identifier"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Char:
+		return "char"
+	case Operator:
+		return "operator"
+	case Directive:
+		return "directive"
+	}
+	return "unknown"
+}
+
+// Abstract texts of identifiers and literals in the normalized stream.
+const (
+	TextIdentifier = "ID"
+	TextNumber     = "NUM"
+	TextString     = "STR"
+	TextChar       = "CHR"
+)
+
+// Token is a lexeme of the source code with its position.
+type Token struct {
+	Kind   Kind
+	Text   string // Normalized text, which is compared.
+	Raw    string // Text in the source.
+	Line   int    // Line from 1.
+	Column int    // Column in characters from 1.
+}
+
+// Options control normalization of the token stream.
+// Comments are never in the stream.
+type Options struct {
+	KeepIdentifiers bool // Identifiers are compared by names.
+	KeepLiterals    bool // Literals are compared by values.
+	KeepImports     bool // Usings, imports and includes stay in the stream.
+	KeepDirectives  bool // Preprocessor directives stay in the stream.
+}
+
+// normalizedText returns the text of the token compared with other tokens.
+func normalizedText(kind Kind, raw string, options Options) string {
+	switch {
+	case kind == Identifier && !options.KeepIdentifiers:
+		return TextIdentifier
+	case kind == Number && !options.KeepLiterals:
+		return TextNumber
+	case kind == String && !options.KeepLiterals:
+		return TextString
+	case kind == Char && !options.KeepLiterals:
+		return TextChar
+	}
+	return raw
+}
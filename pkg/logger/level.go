@@ -0,0 +1,206 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	OutputConsole = "console"
+	OutputFile    = "file"
+)
+
+var ErrUnknownOutput = errors.New("unknown log output")
+
+// LevelOptions describes levels of log outputs.
+// Package levels override output levels for entries from the package.
+type LevelOptions struct {
+	Console  logrus.Level
+	File     logrus.Level
+	Packages map[string]logrus.Level
+}
+
+// ParseLevelOptions parses levels of outputs and a list of package levels
+// in format "checker=debug,task=info".
+func ParseLevelOptions(console, file, packages string) (LevelOptions, error) {
+	var opts LevelOptions
+	var err error
+
+	if opts.Console, err = logrus.ParseLevel(console); err != nil {
+		return opts, err
+	}
+	if opts.File, err = logrus.ParseLevel(file); err != nil {
+		return opts, err
+	}
+
+	opts.Packages = make(map[string]logrus.Level)
+	for _, item := range strings.Split(packages, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pkg, levelStr, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(pkg) == "" {
+			return opts, fmt.Errorf("invalid package level: \"%s\"", item)
+		}
+
+		level, err := logrus.ParseLevel(strings.TrimSpace(levelStr))
+		if err != nil {
+			return opts, err
+		}
+		opts.Packages[strings.TrimSpace(pkg)] = level
+	}
+
+	return opts, nil
+}
+
+// levelControl keeps levels of outputs, which can be changed at runtime.
+type levelControl struct {
+	mu       sync.RWMutex
+	console  logrus.Level
+	file     logrus.Level
+	packages map[string]logrus.Level
+}
+
+func newLevelControl(opts LevelOptions) *levelControl {
+	packages := make(map[string]logrus.Level, len(opts.Packages))
+	for pkg, level := range opts.Packages {
+		packages[pkg] = level
+	}
+
+	return &levelControl{
+		console:  opts.Console,
+		file:     opts.File,
+		packages: packages,
+	}
+}
+
+// enabled checks if the entry has to be written to the output.
+func (c *levelControl) enabled(output string, e *logrus.Entry) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if level, ok := c.packages[entryPackage(e)]; ok {
+		return e.Level <= level
+	}
+
+	if output == OutputFile {
+		return e.Level <= c.file
+	}
+	return e.Level <= c.console
+}
+
+// maxLevel returns the most verbose level of all outputs and packages.
+func (c *levelControl) maxLevel() logrus.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	level := max(c.console, c.file)
+	for _, pkgLevel := range c.packages {
+		level = max(level, pkgLevel)
+	}
+	return level
+}
+
+func (c *levelControl) options() LevelOptions {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	opts := LevelOptions{
+		Console:  c.console,
+		File:     c.file,
+		Packages: make(map[string]logrus.Level, len(c.packages)),
+	}
+	for pkg, level := range c.packages {
+		opts.Packages[pkg] = level
+	}
+	return opts
+}
+
+// entryPackage extracts a short package name of the caller,
+// e.g. "checker" from "CodeBorrowing/internal/checker.(*checkerT).Run".
+func entryPackage(e *logrus.Entry) string {
+	if e.Caller == nil {
+		return ""
+	}
+
+	name := e.Caller.Function
+	if idx := strings.LastIndexByte(name, '/'); idx != -1 {
+		name = name[idx+1:]
+	}
+	if idx := strings.IndexByte(name, '.'); idx != -1 {
+		name = name[:idx]
+	}
+	return name
+}
+
+// levelHook writes entries allowed by the level control to the writer.
+type levelHook struct {
+	writer   io.Writer
+	output   string
+	controls *levelControl
+}
+
+func (h *levelHook) Fire(e *logrus.Entry) error {
+	if !h.controls.enabled(h.output, e) {
+		return nil
+	}
+
+	line, err := e.Bytes()
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(line)
+	return err
+}
+
+func (h *levelHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// GetLevels returns current levels of outputs and packages.
+func (log *Logger) GetLevels() LevelOptions {
+	return log.levels.options()
+}
+
+// SetOutputLevel changes the level of the output ("console" or "file").
+func (log *Logger) SetOutputLevel(output string, level logrus.Level) error {
+	log.levels.mu.Lock()
+	switch output {
+	case OutputConsole:
+		log.levels.console = level
+	case OutputFile:
+		log.levels.file = level
+	default:
+		log.levels.mu.Unlock()
+		return ErrUnknownOutput
+	}
+	log.levels.mu.Unlock()
+
+	log.SetLevel(log.levels.maxLevel())
+	return nil
+}
+
+// SetPackageLevel overrides levels of outputs for entries from the package.
+func (log *Logger) SetPackageLevel(pkg string, level logrus.Level) {
+	log.levels.mu.Lock()
+	log.levels.packages[pkg] = level
+	log.levels.mu.Unlock()
+
+	log.SetLevel(log.levels.maxLevel())
+}
+
+// ResetPackageLevel removes the override of the package.
+func (log *Logger) ResetPackageLevel(pkg string) {
+	log.levels.mu.Lock()
+	delete(log.levels.packages, pkg)
+	log.levels.mu.Unlock()
+
+	log.SetLevel(log.levels.maxLevel())
+}
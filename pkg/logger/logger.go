@@ -8,10 +8,8 @@ import (
 	"runtime"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/sirupsen/logrus"
-	"github.com/sirupsen/logrus/hooks/writer"
 )
 
 type myFormatter struct {
@@ -20,7 +18,15 @@ type myFormatter struct {
 
 type Logger struct {
 	logrus.Logger
-	file *os.File
+	file   *rotatingFile
+	levels *levelControl
+}
+
+// Options of the application logger.
+type Options struct {
+	Path     string          // Directory for log files.
+	Rotation RotationOptions // Rotation and retention of log files.
+	Levels   LevelOptions    // Levels of outputs and packages.
 }
 
 func (f *myFormatter) Format(e *logrus.Entry) ([]byte, error) {
@@ -65,52 +71,49 @@ func (f *myFormatter) extractCallFunction(caller *runtime.Frame) string {
 var instance *Logger
 var once = sync.Once{}
 
-func GetLogger(path string) *Logger {
+func GetLogger(opts Options) *Logger {
 	once.Do(func() {
 		instance = &Logger{
 			Logger: *logrus.New(),
 		}
-		loggerInit(instance, path)
+		loggerInit(instance, opts)
 	})
 	return instance
 }
 
-func loggerInit(log *Logger, path string) {
-	log.SetLevel(logrus.DebugLevel)  // all logs
-	log.SetReportCaller(true)        // info about function-caller
-	log.SetFormatter(&myFormatter{}) // custom output format
+func loggerInit(log *Logger, opts Options) {
+	log.levels = newLevelControl(opts.Levels)
+	log.SetLevel(log.levels.maxLevel()) // the most verbose of outputs
+	log.SetReportCaller(true)           // info about function-caller
+	log.SetFormatter(&myFormatter{})    // custom output format
 
 	log.SetOutput(io.Discard) // Remove all outputs
 
-	log.AddHook(&writer.Hook{
-		Writer:    os.Stdout,
-		LogLevels: logrus.AllLevels,
+	log.AddHook(&levelHook{
+		writer:   os.Stdout,
+		output:   OutputConsole,
+		controls: log.levels,
 	})
 
-	if _, err := utils.CreateDirectory(path); err != nil {
+	if _, err := utils.CreateDirectory(opts.Path); err != nil {
 		panic(err)
 	}
 
-	timeTag := time.Now().Format("2006_01_02_15_04_05") // YYYY_MM_dd_HH_mm_ss
-	logFileName := fmt.Sprintf("%s/%s.log", path, timeTag)
-	file, err := os.OpenFile(logFileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModePerm)
+	file, err := newRotatingFile(opts.Path, opts.Rotation)
 	if err != nil {
 		panic(err)
 	}
 
-	log.AddHook(&writer.Hook{
-		Writer: file,
-		LogLevels: []logrus.Level{logrus.InfoLevel, logrus.WarnLevel,
-			logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel},
+	log.AddHook(&levelHook{
+		writer:   file,
+		output:   OutputFile,
+		controls: log.levels,
 	})
 
 	log.file = file
 }
 
 func (log *Logger) Close() error {
-	if err := log.file.Sync(); err != nil {
-		return err
-	}
 	if err := log.file.Close(); err != nil {
 		return err
 	}
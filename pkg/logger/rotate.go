@@ -0,0 +1,270 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	logFileExt      = ".log"
+	gzipFileExt     = ".gz"
+	logFileTimeTag  = "2006_01_02_15_04_05" // YYYY_MM_dd_HH_mm_ss
+	logFileMaxIndex = 1000
+
+	rotationQueueSize = 16 // Rotations waiting for compression and retention.
+)
+
+// RotationOptions describes when the log file is rotated and how many old files are kept.
+type RotationOptions struct {
+	MaxSize  uint64        // Max size of the current file in bytes (0 - unlimited).
+	Interval time.Duration // Max lifetime of the current file (0 - unlimited).
+	Compress bool          // Gzip rotated files.
+	MaxFiles uint64        // Max count of old files in the directory (0 - unlimited).
+	MaxAge   time.Duration // Max age of old files in the directory (0 - unlimited).
+}
+
+// rotatingFile is a thread-safe writer that switches to a new log file
+// when the current one becomes too big or too old.
+type rotatingFile struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	dir     string
+	opts    RotationOptions
+	file    *os.File
+	size    uint64
+	opened  time.Time
+	current atomic.Pointer[string] // Name of the file, read by the worker without the lock.
+	rotated chan string            // Rotated files waiting for the worker.
+}
+
+func newRotatingFile(dir string, opts RotationOptions) (*rotatingFile, error) {
+	r := &rotatingFile{
+		dir:     dir,
+		opts:    opts,
+		rotated: make(chan string, rotationQueueSize),
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	r.cleanup(r.file.Name())
+
+	r.wg.Add(1)
+	go r.work()
+	return r, nil
+}
+
+// work compresses rotated files and removes old ones one rotation after another,
+// so retention never sees a file being compressed.
+func (r *rotatingFile) work() {
+	defer r.wg.Done()
+
+	for old := range r.rotated {
+		// The file may be already removed by retention, while it waited in the queue.
+		if r.opts.Compress {
+			if err := compressFile(old); err != nil && !os.IsNotExist(err) {
+				_, _ = fmt.Fprintf(os.Stderr, "log rotation: %v\n", err)
+			}
+		}
+		r.cleanup(*r.current.Load())
+	}
+}
+
+// nextFileName returns a name of a new log file that does not exist yet.
+func (r *rotatingFile) nextFileName() string {
+	timeTag := time.Now().Format(logFileTimeTag)
+	name := filepath.Join(r.dir, timeTag+logFileExt)
+
+	for i := 1; i < logFileMaxIndex; i++ {
+		if !fileExists(name) && !fileExists(name+gzipFileExt) {
+			break
+		}
+		name = filepath.Join(r.dir, fmt.Sprintf("%s_%d%s", timeTag, i, logFileExt))
+	}
+
+	return name
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.nextFileName(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	name := file.Name()
+	r.current.Store(&name)
+	r.file = file
+	r.size = uint64(info.Size())
+	r.opened = time.Now()
+	return nil
+}
+
+func (r *rotatingFile) needRotate(n int) bool {
+	if r.opts.MaxSize != 0 && r.size != 0 && r.size+uint64(n) > r.opts.MaxSize {
+		return true
+	}
+	return r.opts.Interval != 0 && time.Since(r.opened) >= r.opts.Interval
+}
+
+// rotate closes the current file and opens a new one.
+// Compression and retention are done in background by the worker.
+func (r *rotatingFile) rotate() error {
+	old := r.file.Name()
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.rotated <- old
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.needRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += uint64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rotated != nil {
+		close(r.rotated)
+		r.rotated = nil
+	}
+	r.wg.Wait()
+
+	if err := r.file.Sync(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
+
+// cleanup removes old log files according to the retention policy.
+// The current file is never removed.
+func (r *rotatingFile) cleanup(current string) {
+	if r.opts.MaxFiles == 0 && r.opts.MaxAge == 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "log retention: %v\n", err)
+		return
+	}
+
+	type oldFile struct {
+		path    string
+		modTime time.Time
+	}
+
+	files := make([]oldFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, logFileExt) || strings.HasSuffix(name, logFileExt+gzipFileExt)) {
+			continue
+		}
+
+		path := filepath.Join(r.dir, name)
+		if path == current {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, oldFile{path: path, modTime: info.ModTime()})
+	}
+
+	// Newest files first.
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	for i, file := range files {
+		expired := r.opts.MaxAge != 0 && time.Since(file.modTime) > r.opts.MaxAge
+		extra := r.opts.MaxFiles != 0 && uint64(i) >= r.opts.MaxFiles
+		if !expired && !extra {
+			continue
+		}
+
+		if err = os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			_, _ = fmt.Fprintf(os.Stderr, "log retention: %v\n", err)
+		}
+	}
+}
+
+// compressFile replaces the file with its gzip version.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path+gzipFileExt, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		_ = gz.Close()
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return err
+	}
+
+	if err = gz.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+
+	// Keep the modification time, so retention treats both files the same way.
+	_ = os.Chtimes(dst.Name(), info.ModTime(), info.ModTime())
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileCompressesAndKeepsFiles(t *testing.T) {
+	dir := t.TempDir()
+	r, err := newRotatingFile(dir, RotationOptions{MaxSize: 64, Compress: true, MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := []byte(strings.Repeat("x", 40) + "\n")
+	for i := 0; i < 200; i++ {
+		if _, err = r.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("got %d files, want 3 old files and the current one", len(entries))
+	}
+
+	plain := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), gzipFileExt) {
+			plain++
+		}
+	}
+	if plain != 1 {
+		t.Errorf("got %d uncompressed files, want only the current one", plain)
+	}
+}